@@ -0,0 +1,346 @@
+package cmmio
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// InstallLayout is where an SDK-style drop of cmmio puts its files, relative to the prefix.
+type InstallLayout struct {
+	Include string // public headers, i.e. <prefix>/<Include>/cmmio/c_mmmq.h
+	Lib     string // static and shared libraries
+	Bin     string // tools and executables
+}
+
+// DefaultInstallLayout returns the layout include/cmmio, lib/ and bin/.
+func DefaultInstallLayout() InstallLayout {
+	return InstallLayout{Include: "include", Lib: "lib", Bin: "bin"}
+}
+
+// platformDefine returns the define that selects the platform code of the library for the host
+// the package is generated on, nmmio has no implementation for other hosts (e.g. Linux).
+func platformDefine() (string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		return "TARGET_PC", nil
+	case "darwin":
+		return "TARGET_MAC", nil
+	}
+	return "", fmt.Errorf("%s: platform '%s' is not supported", repo_name, runtime.GOOS)
+}
+
+// WritePkgConfig writes cmmio.pc for an installation under prefix with the given layout.
+func WritePkgConfig(path string, prefix string, layout InstallLayout) error {
+	define, err := platformDefine()
+	if err != nil {
+		return err
+	}
+	pc := &strings.Builder{}
+	fmt.Fprintf(pc, "prefix=%s\n", filepath.ToSlash(prefix))
+	fmt.Fprintf(pc, "includedir=${prefix}/%s\n", layout.Include)
+	fmt.Fprintf(pc, "libdir=${prefix}/%s\n\n", layout.Lib)
+	fmt.Fprintf(pc, "Name: %s\n", repo_name)
+	fmt.Fprintf(pc, "Description: memory mapped files and a single producer, multiple consumer message queue\n")
+	fmt.Fprintf(pc, "Version: %s\n", version)
+	fmt.Fprintf(pc, "Cflags: -I${includedir} -D%s\n", define)
+	fmt.Fprintf(pc, "Libs: -L${libdir} -l%s -lccore\n", repo_name)
+	fmt.Fprintf(pc, "Libs.private: -lpthread\n")
+	if err := os.WriteFile(path, []byte(pc.String()), 0644); err != nil {
+		return fmt.Errorf("%s: cannot write '%s': %w", repo_name, path, err)
+	}
+	return nil
+}
+
+// mainSources returns the sources of the main library as absolute paths with forward slashes,
+// sorted so that the exported files do not change between runs.
+func mainSources(root string) ([]string, error) {
+	return sourcesIn(root, "main")
+}
+
+// capiSources returns the sources of the C API library like mainSources.
+func capiSources(root string) ([]string, error) {
+	return sourcesIn(root, capiDir)
+}
+
+func sourcesIn(root string, dir string) ([]string, error) {
+	cpp := filepath.Join(root, "source", filepath.FromSlash(dir), "cpp")
+	files, err := filepath.Glob(filepath.Join(cpp, "*.cpp"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s: no sources found in '%s'", repo_name, cpp)
+	}
+	sort.Strings(files)
+	for i := range files {
+		files[i] = filepath.ToSlash(files[i])
+	}
+	return files, nil
+}
+
+// PublicIncludeDir returns the directory that has to be on the include path of code using cmmio,
+// as an absolute path with forward slashes.
+func PublicIncludeDir() string {
+	return filepath.ToSlash(filepath.Join(repoRoot(), "source", "main", "include"))
+}
+
+// capiIncludeDir returns the include directory of the C API library, cmmio/c_cmmio.h, as an
+// absolute path with forward slashes.
+func capiIncludeDir() string {
+	return filepath.ToSlash(filepath.Join(repoRoot(), "source", capiDir, "include"))
+}
+
+// PublicHeaders returns the headers installed with cmmio, relative to PublicIncludeDir and the
+// include directory of an installation (e.g. "cmmio/c_mmmq.h"), sorted.
+func PublicHeaders() ([]string, error) {
+	dir := filepath.Join(repoRoot(), "source", "main", "include", "cmmio")
+	files, err := filepath.Glob(filepath.Join(dir, "*.h"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s: no headers found in '%s'", repo_name, dir)
+	}
+	sort.Strings(files)
+	for i := range files {
+		files[i] = "cmmio/" + filepath.Base(files[i])
+	}
+	return files, nil
+}
+
+// ExportCMake writes CMake files for the main library to dir, for builds that do not use the
+// ccode generator. The target 'cmmio' (alias 'cmmio::cmmio') is declared in cmmioTargets.cmake,
+// CMakeLists.txt makes dir usable with add_subdirectory and cmmioConfig.cmake with find_package.
+// The option CMMIO_STATIC_AND_SHARED adds the shared build 'cmmio_shared' (alias 'cmmio::shared')
+// next to a static 'cmmio', so that an SDK drop can ship both from one configuration.
+// The extern "C" API is the separate target 'cmmio_c' (alias 'cmmio::c') on top of 'cmmio'.
+// The including build has to provide the 'ccore' target.
+func ExportCMake(dir string) error {
+	root := repoRoot()
+	sources, err := mainSources(root)
+	if err != nil {
+		return err
+	}
+	capi, err := capiSources(root)
+	if err != nil {
+		return err
+	}
+
+	targets := &strings.Builder{}
+	fmt.Fprintf(targets, "# generated by cmmio %s (ExportCMake), do not edit\n\n", version)
+	fmt.Fprintf(targets, "if(NOT TARGET ccore)\n    message(FATAL_ERROR \"cmmio: the ccore target has to be defined before cmmio\")\nendif()\n\n")
+	fmt.Fprintf(targets, "# with CMMIO_STATIC_AND_SHARED cmmio is always static and cmmio_shared (cmmio::shared) is\n")
+	fmt.Fprintf(targets, "# built next to it, otherwise cmmio follows BUILD_SHARED_LIBS\n")
+	fmt.Fprintf(targets, "option(CMMIO_STATIC_AND_SHARED \"build a static and a shared cmmio\" OFF)\n\n")
+	fmt.Fprintf(targets, "set(CMMIO_SOURCES\n")
+	for _, src := range sources {
+		fmt.Fprintf(targets, "    \"%s\"\n", src)
+	}
+	fmt.Fprintf(targets, ")\n")
+	fmt.Fprintf(targets, "if(CMMIO_STATIC_AND_SHARED)\n")
+	fmt.Fprintf(targets, "    add_library(cmmio STATIC ${CMMIO_SOURCES})\n")
+	fmt.Fprintf(targets, "    add_library(cmmio_shared SHARED ${CMMIO_SOURCES})\n")
+	fmt.Fprintf(targets, "    add_library(cmmio::shared ALIAS cmmio_shared)\n")
+	fmt.Fprintf(targets, "    # libcmmio.a and libcmmio.so, on Windows the import library would overwrite cmmio.lib\n")
+	fmt.Fprintf(targets, "    if(NOT WIN32)\n        set_target_properties(cmmio_shared PROPERTIES OUTPUT_NAME cmmio)\n    endif()\n")
+	fmt.Fprintf(targets, "    set(CMMIO_TARGETS cmmio cmmio_shared)\n")
+	fmt.Fprintf(targets, "else()\n    add_library(cmmio ${CMMIO_SOURCES})\n    set(CMMIO_TARGETS cmmio)\nendif()\n")
+	fmt.Fprintf(targets, "add_library(cmmio::cmmio ALIAS cmmio)\n\n")
+	fmt.Fprintf(targets, "if(NOT WIN32)\n    find_package(Threads REQUIRED)\nendif()\n\n")
+	fmt.Fprintf(targets, "foreach(t IN LISTS CMMIO_TARGETS)\n")
+	fmt.Fprintf(targets, "    target_include_directories(${t} PUBLIC \"%s\")\n", PublicIncludeDir())
+	fmt.Fprintf(targets, "    target_link_libraries(${t} PUBLIC ccore)\n")
+	fmt.Fprintf(targets, "    if(WIN32)\n        target_compile_definitions(${t} PUBLIC TARGET_PC)\n")
+	fmt.Fprintf(targets, "    elseif(APPLE)\n        target_compile_definitions(${t} PUBLIC TARGET_MAC)\n")
+	fmt.Fprintf(targets, "    else()\n        message(FATAL_ERROR \"cmmio: the platform ${CMAKE_SYSTEM_NAME} is not supported\")\n    endif()\n")
+	fmt.Fprintf(targets, "    target_compile_definitions(${t} PUBLIC $<$<CONFIG:Debug>:TARGET_DEBUG> $<$<NOT:$<CONFIG:Debug>>:TARGET_RELEASE>)\n")
+	fmt.Fprintf(targets, "    # see cmmio/c_api.h\n")
+	fmt.Fprintf(targets, "    get_target_property(cmmio_type ${t} TYPE)\n")
+	fmt.Fprintf(targets, "    if(cmmio_type STREQUAL \"SHARED_LIBRARY\")\n        target_compile_definitions(${t} PUBLIC CMMIO_DLL PRIVATE CMMIO_EXPORTS)\n    endif()\n")
+	fmt.Fprintf(targets, "    # named semaphores\n")
+	fmt.Fprintf(targets, "    if(NOT WIN32)\n        target_link_libraries(${t} PUBLIC Threads::Threads)\n    endif()\n")
+	fmt.Fprintf(targets, "endforeach()\n\n")
+	fmt.Fprintf(targets, "# the extern \"C\" API (cmmio/c_cmmio.h), C++ users of cmmio do not link it\n")
+	fmt.Fprintf(targets, "add_library(cmmio_c\n")
+	for _, src := range capi {
+		fmt.Fprintf(targets, "    \"%s\"\n", src)
+	}
+	fmt.Fprintf(targets, ")\n")
+	fmt.Fprintf(targets, "add_library(cmmio::c ALIAS cmmio_c)\n")
+	fmt.Fprintf(targets, "target_include_directories(cmmio_c PUBLIC \"%s\")\n", capiIncludeDir())
+	fmt.Fprintf(targets, "target_link_libraries(cmmio_c PUBLIC cmmio)\n")
+	fmt.Fprintf(targets, "get_target_property(cmmio_c_type cmmio_c TYPE)\n")
+	fmt.Fprintf(targets, "if(cmmio_c_type STREQUAL \"SHARED_LIBRARY\")\n    target_compile_definitions(cmmio_c PUBLIC CMMIO_DLL PRIVATE CMMIO_EXPORTS)\nendif()\n\n")
+	layout := DefaultInstallLayout()
+	fmt.Fprintf(targets, "# install layout, see DefaultInstallLayout\n")
+	fmt.Fprintf(targets, "install(TARGETS ${CMMIO_TARGETS} cmmio_c ARCHIVE DESTINATION %s LIBRARY DESTINATION %s RUNTIME DESTINATION %s)\n", layout.Lib, layout.Lib, layout.Bin)
+	fmt.Fprintf(targets, "install(DIRECTORY \"%s\" DESTINATION %s)\n", PublicIncludeDir()+"/cmmio", layout.Include)
+	fmt.Fprintf(targets, "install(DIRECTORY \"%s\" DESTINATION %s)\n", capiIncludeDir()+"/cmmio", layout.Include)
+
+	lists := &strings.Builder{}
+	fmt.Fprintf(lists, "# generated by cmmio %s (ExportCMake), do not edit\n\n", version)
+	fmt.Fprintf(lists, "cmake_minimum_required(VERSION 3.16)\n")
+	fmt.Fprintf(lists, "project(cmmio VERSION %s LANGUAGES CXX)\n\n", version)
+	fmt.Fprintf(lists, "include(\"${CMAKE_CURRENT_LIST_DIR}/cmmioTargets.cmake\")\n")
+
+	config := &strings.Builder{}
+	fmt.Fprintf(config, "# generated by cmmio %s (ExportCMake), do not edit\n\n", version)
+	fmt.Fprintf(config, "include_guard(GLOBAL)\n")
+	fmt.Fprintf(config, "set(cmmio_VERSION %s)\n", version)
+	fmt.Fprintf(config, "set(cmmio_ABI_VERSION %d)\n", abi_version)
+	fmt.Fprintf(config, "include(\"${CMAKE_CURRENT_LIST_DIR}/cmmioTargets.cmake\")\n")
+
+	files := map[string]string{
+		"cmmioTargets.cmake": targets.String(),
+		"CMakeLists.txt":     lists.String(),
+		"cmmioConfig.cmake":  config.String(),
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("%s: cannot create '%s': %w", repo_name, dir, err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			return fmt.Errorf("%s: cannot write '%s': %w", repo_name, name, err)
+		}
+	}
+	return nil
+}
+
+// amalgamationInclude returns the header a line includes when it is an '#include "cmmio/..."',
+// those are inlined by the amalgamation and dropped.
+func amalgamationInclude(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "#include") {
+		return "", false
+	}
+	trimmed = strings.TrimSpace(strings.TrimPrefix(trimmed, "#include"))
+	if !strings.HasPrefix(trimmed, "\"cmmio/") || !strings.HasSuffix(trimmed, "\"") {
+		return "", false
+	}
+	return strings.Trim(trimmed, "\""), true
+}
+
+// amalgamationFile reads path and returns its lines without the cmmio includes, together with
+// the cmmio headers it includes.
+func amalgamationFile(path string) ([]string, []string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: cannot read '%s': %w", repo_name, path, err)
+	}
+	defer f.Close()
+	lines := []string{}
+	includes := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if header, ok := amalgamationInclude(scanner.Text()); ok {
+			includes = append(includes, header)
+			continue
+		}
+		lines = append(lines, scanner.Text())
+	}
+	return lines, includes, scanner.Err()
+}
+
+// ExportAmalgamation writes cmmio.h and cmmio.cpp to dir, all public headers and all sources of
+// the main library in one header and one source file for projects that want to drop cmmio into
+// their tree without a build system integration. The headers are ordered so that every one comes
+// after the cmmio headers it includes. The result still includes the ccore headers, code using it
+// needs ccore on the include path and has to define the platform (see platformDefine).
+func ExportAmalgamation(dir string) error {
+	root := repoRoot()
+	headers, err := PublicHeaders()
+	if err != nil {
+		return err
+	}
+	sources, err := mainSources(root)
+	if err != nil {
+		return err
+	}
+
+	headerLines := map[string][]string{}
+	headerIncludes := map[string][]string{}
+	for _, header := range headers {
+		lines, includes, err := amalgamationFile(filepath.Join(PublicIncludeDir(), filepath.FromSlash(header)))
+		if err != nil {
+			return err
+		}
+		headerLines[header] = lines
+		headerIncludes[header] = includes
+	}
+
+	// depth first over the sorted headers, so that the order only changes when the includes do
+	ordered := []string{}
+	state := map[string]int{} // 1 = being visited, 2 = done
+	var visit func(header string, from string) error
+	visit = func(header string, from string) error {
+		switch state[header] {
+		case 1:
+			return fmt.Errorf("%s: include cycle between '%s' and '%s'", repo_name, from, header)
+		case 2:
+			return nil
+		}
+		if _, ok := headerLines[header]; !ok {
+			return fmt.Errorf("%s: '%s' includes '%s', which is not a public header", repo_name, from, header)
+		}
+		state[header] = 1
+		for _, include := range headerIncludes[header] {
+			if err := visit(include, header); err != nil {
+				return err
+			}
+		}
+		state[header] = 2
+		ordered = append(ordered, header)
+		return nil
+	}
+	for _, header := range headers {
+		if err := visit(header, header); err != nil {
+			return err
+		}
+	}
+
+	h := &strings.Builder{}
+	fmt.Fprintf(h, "// generated by cmmio %s (ExportAmalgamation), do not edit\n", version)
+	fmt.Fprintf(h, "// needs ccore on the include path and one of TARGET_PC or TARGET_MAC defined\n")
+	fmt.Fprintf(h, "#ifndef __CMMIO_AMALGAMATION_H__\n#define __CMMIO_AMALGAMATION_H__\n")
+	for _, header := range ordered {
+		fmt.Fprintf(h, "\n// ---- %s ----\n", header)
+		fmt.Fprintf(h, "%s\n", strings.Join(headerLines[header], "\n"))
+	}
+	fmt.Fprintf(h, "\n#endif  // __CMMIO_AMALGAMATION_H__\n")
+
+	cpp := &strings.Builder{}
+	fmt.Fprintf(cpp, "// generated by cmmio %s (ExportAmalgamation), do not edit\n", version)
+	fmt.Fprintf(cpp, "#include \"cmmio.h\"\n")
+	for _, src := range sources {
+		lines, includes, err := amalgamationFile(filepath.FromSlash(src))
+		if err != nil {
+			return err
+		}
+		for _, include := range includes {
+			if _, ok := headerLines[include]; !ok {
+				return fmt.Errorf("%s: '%s' includes '%s', which is not a public header", repo_name, filepath.Base(src), include)
+			}
+		}
+		fmt.Fprintf(cpp, "\n// ---- %s ----\n", filepath.Base(src))
+		fmt.Fprintf(cpp, "%s\n", strings.Join(lines, "\n"))
+	}
+
+	files := map[string]string{
+		"cmmio.h":   h.String(),
+		"cmmio.cpp": cpp.String(),
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("%s: cannot create '%s': %w", repo_name, dir, err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			return fmt.Errorf("%s: cannot write '%s': %w", repo_name, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package cmmio
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Feature describes a build feature of cmmio for meta-build tooling.
+type Feature struct {
+	Name        string
+	Description string
+	Available   bool     // false for features that are known but not implemented in this version
+	Platforms   []string // runtime.GOOS values on which the feature works, empty when not available
+}
+
+// features lists what this version of cmmio provides, the library code on POSIX systems is
+// compiled under TARGET_MAC only and the TARGET_PC branch of nmmio is incomplete.
+var features = []Feature{
+	{Name: "mmap", Description: "memory mapped files using POSIX mmap (nmmio)", Available: true, Platforms: []string{"darwin"}},
+	{Name: "spmc", Description: "single producer, multiple consumer queue over mapped files (nmmmq)", Available: true, Platforms: []string{"darwin"}},
+	{Name: "c-api", Description: "flat extern \"C\" API, the cmmio_c library and cmmio/c_cmmio.h", Available: true, Platforms: []string{"darwin"}},
+	{Name: "capture", Description: "versioned capture file reader and writer (ncapture), cmmio/c_capture.h", Available: true, Platforms: []string{"darwin"}},
+	{Name: "permissions", Description: "POSIX mode bits of the channel files and semaphores, nmmmq::config_t::mode", Available: true, Platforms: []string{"darwin"}},
+	{Name: "scheduler", Description: "weighted round-robin drain over several channels (nscheduler), cmmio/c_scheduler.h", Available: true, Platforms: []string{"darwin"}},
+	{Name: "shared", Description: "shared library build with CMMIO_DLL, see ExportCMake", Available: true, Platforms: []string{"darwin"}},
+	{Name: "win32", Description: "memory mapped files using CreateFileMapping"},
+	{Name: "posix-shm", Description: "channels in shm_open segments instead of files"},
+	{Name: "hugepages", Description: "mappings backed by huge pages"},
+	{Name: "mpmc", Description: "multiple concurrent producers on one channel"},
+	{Name: "persistence", Description: "channels that survive a restart of the producer"},
+}
+
+// Features returns all features known to cmmio, sorted by name.
+func Features() []Feature {
+	list := make([]Feature, len(features))
+	copy(list, features)
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// ValidateFeatures returns an error when one of names is unknown, not implemented or does not
+// work on goos (a runtime.GOOS value).
+func ValidateFeatures(names []string, goos string) error {
+	for _, name := range names {
+		var feature *Feature
+		for i := range features {
+			if features[i].Name == name {
+				feature = &features[i]
+				break
+			}
+		}
+		if feature == nil {
+			return fmt.Errorf("%s: unknown feature '%s'", repo_name, name)
+		}
+		if !feature.Available {
+			return fmt.Errorf("%s: feature '%s' is not available in version %s", repo_name, name, version)
+		}
+		supported := false
+		for _, platform := range feature.Platforms {
+			supported = supported || platform == goos
+		}
+		if !supported {
+			return fmt.Errorf("%s: feature '%s' is not supported on '%s'", repo_name, name, goos)
+		}
+	}
+	return nil
+}
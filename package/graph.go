@@ -0,0 +1,128 @@
+package cmmio
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// GraphTarget is one project of the package graph described by DescribeGraph.
+type GraphTarget struct {
+	Name         string      `json:"name"`
+	Kind         string      `json:"kind"`          // "mainlib", "testlib", "unittest" or "app"
+	Dir          string      `json:"dir,omitempty"` // source/<dir>/cpp of an app or of the C API targets
+	Dependencies []string    `json:"dependencies"`  // "<package>/<target>", e.g. "ccore/mainlib"
+	Data         []GraphData `json:"data,omitempty"`
+	Runs         []string    `json:"runs,omitempty"` // apps this app starts from its own output directory
+}
+
+// GraphData is a rule copying the files in Dir that match Glob to To next to the executable,
+// recursive rules are expanded to the sub directories that exist in the repository.
+type GraphData struct {
+	Dir  string `json:"dir"`
+	Glob string `json:"glob"`
+	To   string `json:"to"`
+}
+
+// Graph is the resolved package for a set of options.
+type Graph struct {
+	Package    string        `json:"package"` // "<repo path>/<repo name>"
+	Version    string        `json:"version"`
+	ABIVersion int           `json:"abi_version"`
+	Packages   []string      `json:"packages"` // dependency packages added to the package
+	Targets    []GraphTarget `json:"targets"`
+}
+
+// resolveGraph mirrors newPackage without creating any denv projects.
+func resolveGraph(opts Options) Graph {
+	name := repo_name
+	g := Graph{
+		Package:    filepath.ToSlash(repoPath()) + "/" + repoName(),
+		Version:    version,
+		ABIVersion: abi_version,
+		Packages:   []string{"ccore"},
+		Targets:    []GraphTarget{},
+	}
+	g.Targets = append(g.Targets, GraphTarget{Name: name, Kind: "mainlib", Dependencies: []string{"ccore/mainlib"}})
+
+	if opts.BuildTests {
+		g.Packages = append(g.Packages, "cunittest")
+		g.Targets = append(g.Targets, GraphTarget{Name: name, Kind: "testlib", Dependencies: []string{"ccore/testlib", "cunittest/testlib"}})
+		g.Targets = append(g.Targets, GraphTarget{Name: name, Kind: "unittest", Dependencies: []string{"cunittest/mainlib", name + "/testlib"}, Data: graphData(testData)})
+	}
+
+	if opts.BuildCAPI {
+		g.Targets = append(g.Targets, GraphTarget{Name: capiName, Kind: "mainlib", Dir: capiDir, Dependencies: []string{name + "/mainlib"}})
+		if opts.BuildTests {
+			g.Targets = append(g.Targets, GraphTarget{Name: capiName, Kind: "testlib", Dir: capiDir, Dependencies: []string{name + "/testlib"}})
+			g.Targets = append(g.Targets, GraphTarget{Name: capiName, Kind: "unittest", Dir: capiTestDir, Dependencies: []string{"cunittest/mainlib", capiName + "/testlib"}})
+		}
+	}
+
+	if opts.BuildApps {
+		g.Packages = append(g.Packages, "centry")
+		for _, a := range selectedApps(opts) {
+			runs := []string{}
+			for _, run := range a.runs {
+				runs = append(runs, opts.AppPrefix+run)
+			}
+			g.Targets = append(g.Targets, GraphTarget{Name: opts.AppPrefix + a.name, Kind: "app", Dir: a.dir, Dependencies: []string{"centry/mainlib", name + "/mainlib"}, Data: graphData(a.data), Runs: runs})
+		}
+	}
+	return g
+}
+
+func graphData(rules []dataRule) []GraphData {
+	data := []GraphData{}
+	for _, rule := range expandRules(rules) {
+		data = append(data, GraphData{Dir: rule.dir, Glob: rule.glob, To: rule.to})
+	}
+	return data
+}
+
+// DescribeGraph returns the package for opts as JSON, the targets with their dependencies and
+// the files copied next to them, without generating any projects. The descriptor adds no
+// defines or configurations of its own, those come from the generator. The output is stable
+// for the same options and repository, so two runs can be diffed to see what an option changes.
+func DescribeGraph(opts Options) ([]byte, error) {
+	return json.MarshalIndent(resolveGraph(opts), "", "  ")
+}
+
+// verifyGraph checks that every app that starts other apps finds them next to it. The app
+// does not know Options.AppPrefix, it takes the prefix from its own executable name, see
+// init_bin_dir in integration.cpp, so the names are derived here the same way.
+func verifyGraph(opts Options) error {
+	g := resolveGraph(opts)
+	built := map[string]bool{}
+	for _, t := range g.Targets {
+		if t.Kind == "app" {
+			built[t.Name] = true
+		}
+	}
+	for _, a := range apps {
+		exe := opts.AppPrefix + a.name
+		if len(a.runs) == 0 || !built[exe] {
+			continue
+		}
+		prefix := exePrefix(exe, a.name)
+		for _, run := range a.runs {
+			if !built[prefix+run] {
+				return fmt.Errorf("%s: app '%s' runs '%s', which is not built next to it", repo_name, exe, prefix+run)
+			}
+		}
+	}
+	return nil
+}
+
+// exePrefix is the prefix an app finds in front of its own name @app in its executable name
+// @exe. Like integration.cpp it only looks at the file name and finds none when the prefix
+// does not fit its 128 byte buffer.
+func exePrefix(exe string, app string) string {
+	name := path.Base(filepath.ToSlash(exe))
+	if len(name) <= len(app) || !strings.HasSuffix(name, app) || len(name)-len(app) >= 128 {
+		return ""
+	}
+	return strings.TrimSuffix(name, app)
+}
@@ -1,6 +1,16 @@
 package cmmio
 
 import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
 	"github.com/jurgen-kluft/ccode/denv"
 	ccore "github.com/jurgen-kluft/ccore/package"
 	centry "github.com/jurgen-kluft/centry/package"
@@ -8,46 +18,735 @@ import (
 )
 
 const (
-	repo_path = "github.com\\jurgen-kluft"
+	repo_path = "github.com/jurgen-kluft"
 	repo_name = "cmmio"
+
+	// keep in sync with source/main/include/cmmio/c_version.h, GetPackageChecked verifies it
+	version     = "0.1.0"
+	abi_version = 1
+)
+
+// Version returns the version of cmmio as "major.minor.patch".
+func Version() string { return version }
+
+// ABIVersion returns the version of the shared memory layout, processes can only share a
+// channel when they are built against the same ABI version.
+func ABIVersion() int { return abi_version }
+
+// Metadata describes the package for downstream build graphs.
+type Metadata struct {
+	Name       string
+	Version    string
+	ABIVersion int
+}
+
+// GetMetadata returns the metadata of the cmmio package.
+func GetMetadata() Metadata {
+	return Metadata{Name: repo_name, Version: version, ABIVersion: abi_version}
+}
+
+// RequireVersion returns an error when cmmio is older than min ("major.minor.patch").
+func RequireVersion(min string) error {
+	have, err := parseVersion(version)
+	if err != nil {
+		return err
+	}
+	want, err := parseVersion(min)
+	if err != nil {
+		return err
+	}
+	for i := range have {
+		if have[i] != want[i] {
+			if have[i] < want[i] {
+				return fmt.Errorf("%s: version %s is older than the required %s", repo_name, version, min)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+func parseVersion(v string) ([3]int, error) {
+	var parsed [3]int
+	if _, err := fmt.Sscanf(v, "%d.%d.%d", &parsed[0], &parsed[1], &parsed[2]); err != nil {
+		return parsed, fmt.Errorf("%s: invalid version '%s', expected major.minor.patch", repo_name, v)
+	}
+	return parsed, nil
+}
+
+// set by SetRepo, empty means the default
+var (
+	repoPathOverride string
+	repoNameOverride string
+)
+
+// SetRepo overrides the owner path and the name of the repository the package is generated for,
+// e.g. SetRepo("git.company.com/engine", "cmmio") for an internal mirror. An empty argument
+// keeps the default. Call it before the first GetPackage, packages that were already created
+// keep the path they were created with.
+func SetRepo(path string, name string) {
+	repoPathOverride = path
+	repoNameOverride = name
+}
+
+// repoPath returns the path of the repository owner with native separators. SetRepo, or else
+// the environment variable CMMIO_REPO_PATH (e.g. "github.com/myname" for a fork), overrides
+// the default.
+func repoPath() string {
+	path := repoPathOverride
+	if path == "" {
+		path = os.Getenv("CMMIO_REPO_PATH")
+	}
+	if path == "" {
+		path = repo_path
+	}
+	return filepath.FromSlash(path)
+}
+
+// repoName returns the name of the repository, see SetRepo.
+func repoName() string {
+	if repoNameOverride != "" {
+		return repoNameOverride
+	}
+	return repo_name
+}
+
+// Options selects which targets end up in the package returned by GetPackageWithOptions.
+type Options struct {
+	BuildApps     bool     // all application targets and the centry dependency
+	BuildProducer bool     // the producer application (and cmmio-integration, which runs it), only used when BuildApps is set
+	BuildWorkload bool     // the cmmio-workload traffic generator, only used when BuildApps is set
+	BuildIPCBench bool     // the cmmio-ipc-bench standard IPC baseline, only used when BuildApps is set
+	BuildTests    bool     // the test library, the unittest and the cunittest dependency
+	BuildCAPI     bool     // the cmmio_c library (source/capi) with the extern "C" API, and its tests with BuildTests
+	SharedLib     bool     // the main library as a shared library, denv cannot declare one (see ExportCMake)
+	Features      []string // features the caller relies on, see Features, all but c-api (BuildCAPI) are part of the main library
+	AppPrefix     string   // prepended to the project name of every application, e.g. "cmmio_"
+	Deps          Deps     // dependency packages supplied by the caller
+}
+
+// key returns a comparable form of o for the package cache, the dependency packages are
+// compared by identity.
+func (o Options) key() string {
+	features := strings.Join(o.Features, ",")
+	o.Features = nil
+	return fmt.Sprintf("%+v features=%s", o, features)
+}
+
+// checkOptions rejects the options denv cannot honour.
+func checkOptions(opts Options) error {
+	if opts.SharedLib {
+		return fmt.Errorf("%s: a shared main library cannot be declared with denv, use ExportCMake", repo_name)
+	}
+	for _, feature := range opts.Features {
+		if feature == "c-api" && !opts.BuildCAPI {
+			return fmt.Errorf("%s: feature 'c-api' needs BuildCAPI", repo_name)
+		}
+	}
+	return ValidateFeatures(opts.Features, runtime.GOOS)
+}
+
+// Deps lets a caller supply already constructed dependency packages (e.g. a locally patched
+// ccore), a nil entry means the package is resolved by cmmio itself.
+type Deps struct {
+	CCore     *denv.Package
+	CEntry    *denv.Package
+	CUnittest *denv.Package
+}
+
+func (d Deps) ccore() *denv.Package {
+	if d.CCore != nil {
+		return d.CCore
+	}
+	return ccorePackage()
+}
+
+func (d Deps) centry() *denv.Package {
+	if d.CEntry != nil {
+		return d.CEntry
+	}
+	return centryPackage()
+}
+
+func (d Deps) cunittest() *denv.Package {
+	if d.CUnittest != nil {
+		return d.CUnittest
+	}
+	return cunittestPackage()
+}
+
+// DefaultOptions returns the options used by GetPackage, everything is enabled.
+func DefaultOptions() Options {
+	return Options{
+		BuildApps:     true,
+		BuildCAPI:     true,
+		BuildProducer: true,
+		BuildWorkload: true,
+		BuildIPCBench: true,
+		BuildTests:    true,
+	}
+}
+
+// DependencyOptions returns the options for using cmmio as a dependency of another package, only
+// the main library: no test projects, no applications and no cunittest or centry dependency.
+func DependencyOptions() Options {
+	return Options{}
+}
+
+// TestOptions returns the options for building and running only the tests of cmmio: the main
+// library, the test library and the unittest with its fixtures, and the same for the C API,
+// without applications and centry.
+func TestOptions() Options {
+	return Options{BuildTests: true, BuildCAPI: true}
+}
+
+// app is an application project, sources are in source/<dir>/cpp, data lists the files that
+// are copied next to the executable and runs the apps it starts from its own output directory
+type app struct {
+	name string
+	dir  string
+	data []dataRule
+	runs []string
+}
+
+// capiName is the C API library, its sources are in source/<capiDir>/cpp and its unittest in
+// source/<capiTestDir>/cpp.
+const (
+	capiName    = repo_name + "_c"
+	capiDir     = "capi"
+	capiTestDir = "capi/test"
 )
 
+var apps = []app{
+	{name: "producer", dir: "producer", data: []dataRule{{dir: "source/producer/data", glob: "*.json", to: "data"}}}, // sample config and its schema
+	{name: "consumer", dir: "consumer"},
+	{name: "cmmio-file-producer", dir: "file-producer"},
+	{name: "cmmio-synthetic-producer", dir: "synthetic-producer"},
+	{name: "cmmio-workload", dir: "workload"},           // optional, see Options.BuildWorkload
+	{name: "cmmio-bench", dir: "bench", data: testData}, // shares the unittest fixtures
+	{name: "cmmio-ipc-bench", dir: "ipc-bench"},         // optional, see Options.BuildIPCBench
+	{name: "cmmio-bench-compare", dir: "bench-compare", data: []dataRule{{dir: "source/bench-compare/data", glob: "*.json", to: "data"}}},
+	{name: "cmmio-latency-report", dir: "latency-report"},
+	{name: "mmio-inspect", dir: "inspect"},
+	{name: "cmmio-ctl", dir: "ctl"},
+	{name: "cmmio-tail", dir: "tail"},
+	{name: "cmmio-replay", dir: "replay", data: []dataRule{{dir: "source/replay/data", glob: "*.bin", to: "data"}}},
+	{name: "cmmio-convert", dir: "convert"},
+	{name: "cmmio-trace", dir: "trace"},
+	{name: "cmmio-health", dir: "health"},
+	{name: "cmmio-soak", dir: "soak"}, // deliberately not part of the unittest
+	{name: "cmmio-integration", dir: "integration", data: testData, runs: []string{"producer", "consumer"}},
+	{name: "cmmio-metrics", dir: "metrics"},
+	{name: "testdata-gen", dir: "testdata-gen"}, // writes the fixtures copied by maintest
+	{name: "hello-producer", dir: "examples/hello-producer"},
+	{name: "hello-consumer", dir: "examples/hello-consumer"},
+	{name: "file-backed-log", dir: "examples/file-backed-log"},
+	{name: "multi-channel", dir: "examples/multi-channel"},
+}
+
+// dataRule copies the files in dir that match glob to the folder 'to' next to the executable,
+// a recursive rule also copies the matching files of every sub directory of dir to the same
+// relative location under 'to' (e.g. data/corrupt/).
+type dataRule struct {
+	dir       string
+	glob      string
+	to        string
+	recursive bool
+}
+
+// test fixtures copied next to the unittest executable, the SHA-256 of every one of them is
+// recorded in testDataManifest (in testDataDir)
+const (
+	testDataDir      = "source/test/data"
+	testDataManifest = "manifest.sha256"
+)
+
+var testData = []dataRule{
+	{dir: testDataDir, glob: "*.bin", to: "data", recursive: true}, // binary fixtures
+	{dir: testDataDir, glob: "*.json", to: "data"},                 // channel configurations
+	{dir: testDataDir, glob: "*.txt", to: "data"},                  // expected output
+}
+
+// copyToOutput adds the rules to prj, see expandRules for the recursive ones.
+func copyToOutput(prj *denv.DevProject, rules []dataRule) {
+	for _, rule := range expandRules(rules) {
+		prj.CopyToOutput(rule.dir, rule.glob, rule.to)
+	}
+}
+
+// expandRules returns rules with every recursive rule expanded into one rule per sub directory
+// that exists in the repository at the time the package is generated.
+func expandRules(rules []dataRule) []dataRule {
+	expanded := []dataRule{}
+	for _, rule := range rules {
+		expanded = append(expanded, dataRule{dir: rule.dir, glob: rule.glob, to: rule.to})
+		if !rule.recursive {
+			continue
+		}
+		root := filepath.Join(repoRoot(), filepath.FromSlash(rule.dir))
+		filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil || !d.IsDir() || path == root {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return nil
+			}
+			rel = filepath.ToSlash(rel)
+			expanded = append(expanded, dataRule{dir: rule.dir + "/" + rel, glob: rule.glob, to: rule.to + "/" + rel})
+			return nil
+		})
+	}
+	return expanded
+}
+
+// Targets gives access to the individual projects of a package, so that a downstream
+// descriptor can add dependencies to a specific cmmio target. Projects that are disabled
+// by the options are nil (or absent from Apps).
+type Targets struct {
+	Package   *denv.Package
+	MainLib   *denv.DevProject
+	TestLib   *denv.DevProject
+	UnitTest  *denv.DevProject
+	CLib      *denv.DevProject // cmmio_c, see Options.BuildCAPI
+	CTestLib  *denv.DevProject
+	CUnitTest *denv.DevProject
+	Apps      map[string]*denv.DevProject // by app name without Options.AppPrefix, e.g. "producer"
+}
+
+// targets caches the package per set of options, callers that ask for the same options
+// get the same *denv.Package so the generated workspace does not contain duplicate nodes.
+var targets = map[string]*Targets{}
+
 func GetPackage() *denv.Package {
+	return GetPackageWithOptions(DefaultOptions())
+}
+
+func GetPackageWithOptions(opts Options) *denv.Package {
+	return GetTargets(opts).Package
+}
+
+// GetDependencyPackage returns the package for DependencyOptions, for descriptors that add cmmio
+// to their own package with AddPackage and only link against its main library.
+func GetDependencyPackage() *denv.Package {
+	return GetPackageWithOptions(DependencyOptions())
+}
+
+// GetTestPackage returns the package for TestOptions, e.g. for a CI workspace that only runs
+// the unittest.
+func GetTestPackage() *denv.Package {
+	return GetPackageWithOptions(TestOptions())
+}
+
+// GetPackageWith returns the default package built on top of the supplied dependencies.
+func GetPackageWith(deps Deps) *denv.Package {
+	opts := DefaultOptions()
+	opts.Deps = deps
+	return GetPackageWithOptions(opts)
+}
+
+// GetTargets returns the package for opts together with its projects.
+func GetTargets(opts Options) *Targets {
+	key := opts.key()
+	if t, ok := targets[key]; ok {
+		return t
+	}
+	t := newPackage(opts)
+	targets[key] = t
+	return t
+}
+
+// GetMainLibProject returns the main library project of the default package.
+func GetMainLibProject() *denv.DevProject {
+	return GetTargets(DefaultOptions()).MainLib
+}
+
+// GetTestLibProject returns the test library project of the default package.
+func GetTestLibProject() *denv.DevProject {
+	return GetTargets(DefaultOptions()).TestLib
+}
+
+// GetUnitTestProject returns the unittest project of the default package.
+func GetUnitTestProject() *denv.DevProject {
+	return GetTargets(DefaultOptions()).UnitTest
+}
+
+// GetAppProject returns the application project called name of the default package, or nil.
+func GetAppProject(name string) *denv.DevProject {
+	return GetTargets(DefaultOptions()).Apps[name]
+}
+
+// GetProducerApp returns the producer application project of the default package.
+func GetProducerApp() *denv.DevProject {
+	return GetAppProject("producer")
+}
+
+// dependencies holds the dependency packages by name, each one is created once and then
+// shared by every package variant built by this process.
+var dependencies = map[string]*denv.Package{}
+
+func dependency(name string, get func() *denv.Package) *denv.Package {
+	if pkg, ok := dependencies[name]; ok {
+		return pkg
+	}
+	pkg := get()
+	dependencies[name] = pkg
+	return pkg
+}
+
+func ccorePackage() *denv.Package     { return dependency("ccore", ccore.GetPackage) }
+func centryPackage() *denv.Package    { return dependency("centry", centry.GetPackage) }
+func cunittestPackage() *denv.Package { return dependency("cunittest", cunittest.GetPackage) }
+
+// GetPackageChecked is GetPackageWithOptions, but first rejects options denv cannot honour
+// (SharedLib, unavailable Features) and verifies that the source layout the projects are
+// generated from exists and that all dependency packages resolved.
+func GetPackageChecked(opts Options) (*denv.Package, error) {
+	if err := checkOptions(opts); err != nil {
+		return nil, err
+	}
+	if err := checkLayout(repoRoot(), opts); err != nil {
+		return nil, err
+	}
+	pkg := GetPackageWithOptions(opts)
+	if pkg == nil {
+		return nil, fmt.Errorf("%s: package could not be created", repo_name)
+	}
+	return pkg, nil
+}
+
+// repoRoot returns the root of the cmmio repository, this file lives in <root>/package.
+// The environment variable CMMIO_ROOT overrides it, e.g. when the package is compiled
+// from a different location than the checkout it generates for.
+func repoRoot() string {
+	if root := os.Getenv("CMMIO_ROOT"); root != "" {
+		return filepath.Clean(root)
+	}
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(filepath.Dir(file))
+}
+
+func checkLayout(root string, opts Options) error {
+	dirs := []string{"source/main/cpp", "source/main/include/cmmio"}
+	if opts.BuildTests {
+		dirs = append(dirs, "source/test/cpp", "source/test/data")
+	}
+	if opts.BuildCAPI {
+		dirs = append(dirs, "source/"+capiDir+"/cpp", "source/"+capiDir+"/include/cmmio")
+		if opts.BuildTests {
+			dirs = append(dirs, "source/"+capiTestDir+"/cpp")
+		}
+	}
+	for _, a := range selectedApps(opts) {
+		dirs = append(dirs, "source/"+a.dir+"/cpp")
+		for _, rule := range a.data {
+			dirs = append(dirs, rule.dir)
+		}
+	}
+
+	for _, dir := range dirs {
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(dir)))
+		if err != nil {
+			return fmt.Errorf("%s: missing directory '%s' in '%s': %w", repo_name, dir, root, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s: '%s' in '%s' is not a directory", repo_name, dir, root)
+		}
+	}
+
+	deps := map[string]*denv.Package{"ccore": opts.Deps.ccore()}
+	if opts.BuildApps {
+		deps["centry"] = opts.Deps.centry()
+	}
+	if opts.BuildTests {
+		deps["cunittest"] = opts.Deps.cunittest()
+	}
+	for name, dep := range deps {
+		if dep == nil {
+			return fmt.Errorf("%s: dependency package '%s' did not resolve", repo_name, name)
+		}
+	}
+
+	if err := verifyVersionHeader(root); err != nil {
+		return err
+	}
+	if err := verifyGraph(opts); err != nil {
+		return err
+	}
+	if opts.BuildTests {
+		return verifyTestData(root)
+	}
+	return nil
+}
+
+// versionHeader is compiled into the library, it has to describe the same version as the package.
+const versionHeader = "source/main/include/cmmio/c_version.h"
+
+// verifyVersionHeader checks CMMIO_VERSION and CMMIO_ABI_VERSION in versionHeader against version
+// and abi_version, so that the library and the build graph cannot disagree about the version.
+func verifyVersionHeader(root string) error {
+	path := filepath.Join(root, filepath.FromSlash(versionHeader))
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("%s: cannot read '%s': %w", repo_name, versionHeader, err)
+	}
+	defer f.Close()
+
+	headerVersion, headerABI := "", -1
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var name, value string
+		if _, err := fmt.Sscanf(scanner.Text(), "#define %s %s", &name, &value); err != nil {
+			continue
+		}
+		switch name {
+		case "CMMIO_VERSION":
+			headerVersion = strings.Trim(value, "\"")
+		case "CMMIO_ABI_VERSION":
+			fmt.Sscanf(value, "%d", &headerABI)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: cannot read '%s': %w", repo_name, versionHeader, err)
+	}
+	if headerVersion != version {
+		return fmt.Errorf("%s: '%s' has version '%s', the package is %s", repo_name, versionHeader, headerVersion, version)
+	}
+	if headerABI != abi_version {
+		return fmt.Errorf("%s: '%s' has ABI version %d, the package has %d", repo_name, versionHeader, headerABI, abi_version)
+	}
+	return nil
+}
+
+// ruleFiles returns the files matched by the rules, relative to dir and with forward slashes.
+func ruleFiles(root string, dir string, rules []dataRule) ([]string, error) {
+	base := filepath.Join(root, filepath.FromSlash(dir))
+	files := []string{}
+	for _, rule := range rules {
+		ruleDir := filepath.Join(root, filepath.FromSlash(rule.dir))
+		err := filepath.WalkDir(ruleDir, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != ruleDir && !rule.recursive {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if match, _ := filepath.Match(rule.glob, d.Name()); !match {
+				return nil
+			}
+			rel, err := filepath.Rel(base, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readManifest reads a manifest in the format of sha256sum ("<hex>  <file>" per line).
+func readManifest(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var sum, file string
+		if _, err := fmt.Sscanf(scanner.Text(), "%s %s", &sum, &file); err != nil {
+			return nil, fmt.Errorf("%s: line %d of '%s' is not '<sha256>  <file>'", repo_name, line, path)
+		}
+		sums[file] = sum
+	}
+	return sums, scanner.Err()
+}
+
+// verifyTestData checks every file copied for the unittest against the manifest, so that a
+// stale or corrupted fixture is reported when generating instead of by a failing test.
+func verifyTestData(root string) error {
+	manifest := filepath.Join(root, filepath.FromSlash(testDataDir), testDataManifest)
+	sums, err := readManifest(manifest)
+	if err != nil {
+		return fmt.Errorf("%s: cannot read test data manifest (run UpdateTestDataManifest): %w", repo_name, err)
+	}
+	files, err := ruleFiles(root, testDataDir, testData)
+	if err != nil {
+		return fmt.Errorf("%s: cannot list test data: %w", repo_name, err)
+	}
+	for _, file := range files {
+		want, ok := sums[file]
+		if !ok {
+			return fmt.Errorf("%s: test data '%s' is not listed in '%s'", repo_name, file, manifest)
+		}
+		have, err := fileSHA256(filepath.Join(root, filepath.FromSlash(testDataDir), filepath.FromSlash(file)))
+		if err != nil {
+			return fmt.Errorf("%s: cannot hash test data '%s': %w", repo_name, file, err)
+		}
+		if have != want {
+			return fmt.Errorf("%s: test data '%s' does not match its checksum in '%s' (stale or corrupted fixture)", repo_name, file, manifest)
+		}
+		delete(sums, file)
+	}
+	for file := range sums {
+		return fmt.Errorf("%s: test data '%s' listed in '%s' does not exist", repo_name, file, manifest)
+	}
+	return nil
+}
+
+// UpdateTestDataManifest rewrites the manifest of the unittest fixtures, call it after
+// intentionally changing or adding a fixture (e.g. after running testdata-gen).
+func UpdateTestDataManifest() error {
+	root := repoRoot()
+	files, err := ruleFiles(root, testDataDir, testData)
+	if err != nil {
+		return fmt.Errorf("%s: cannot list test data: %w", repo_name, err)
+	}
+	manifest := ""
+	for _, file := range files {
+		sum, err := fileSHA256(filepath.Join(root, filepath.FromSlash(testDataDir), filepath.FromSlash(file)))
+		if err != nil {
+			return fmt.Errorf("%s: cannot hash test data '%s': %w", repo_name, file, err)
+		}
+		manifest += sum + "  " + file + "\n"
+	}
+	return os.WriteFile(filepath.Join(root, filepath.FromSlash(testDataDir), testDataManifest), []byte(manifest), 0644)
+}
+
+// selectedApps returns the entries of apps that are enabled by opts.
+func selectedApps(opts Options) []app {
+	selected := []app{}
+	if !opts.BuildApps {
+		return selected
+	}
+	for _, a := range apps {
+		if a.name == "producer" && !opts.BuildProducer {
+			continue
+		}
+		if a.name == "cmmio-workload" && !opts.BuildWorkload {
+			continue
+		}
+		if a.name == "cmmio-ipc-bench" && !opts.BuildIPCBench {
+			continue
+		}
+		selected = append(selected, a)
+	}
+
+	// an app that starts other apps cannot work without them, e.g. cmmio-integration without
+	// the producer
+	built := map[string]bool{}
+	for _, a := range selected {
+		built[a.name] = true
+	}
+	runnable := []app{}
+	for _, a := range selected {
+		ok := true
+		for _, name := range a.runs {
+			ok = ok && built[name]
+		}
+		if ok {
+			runnable = append(runnable, a)
+		}
+	}
+	return runnable
+}
+
+func newPackage(opts Options) *Targets {
 	name := repo_name
 
 	// dependencies
-	cunittestpkg := cunittest.GetPackage()
-	ccorepkg := ccore.GetPackage()
-	centrypkg := centry.GetPackage()
+	ccorepkg := opts.Deps.ccore()
 
 	// main package
-	mainpkg := denv.NewPackage(repo_path, repo_name)
-	mainpkg.AddPackage(cunittestpkg)
+	mainpkg := denv.NewPackage(repoPath(), repoName())
 	mainpkg.AddPackage(ccorepkg)
 
 	// main library
 	mainlib := denv.SetupCppLibProject(mainpkg, name)
 	mainlib.AddDependencies(ccorepkg.GetMainLib())
 
-	// test library
-	testlib := denv.SetupCppTestLibProject(mainpkg, name)
-	testlib.AddDependencies(ccorepkg.GetTestLib())
-	testlib.AddDependencies(cunittestpkg.GetTestLib())
+	t := &Targets{Package: mainpkg, MainLib: mainlib, Apps: map[string]*denv.DevProject{}}
+
+	if opts.BuildTests {
+		cunittestpkg := opts.Deps.cunittest()
+		mainpkg.AddPackage(cunittestpkg)
+
+		// test library
+		testlib := denv.SetupCppTestLibProject(mainpkg, name)
+		testlib.AddDependencies(ccorepkg.GetTestLib())
+		testlib.AddDependencies(cunittestpkg.GetTestLib())
+
+		// unittest project
+		maintest := denv.SetupCppTestProject(mainpkg, name)
+		copyToOutput(maintest, testData)
+		maintest.AddDependencies(cunittestpkg.GetMainLib())
+		maintest.AddDependency(testlib)
+
+		mainpkg.AddTestLib(testlib)
+		mainpkg.AddUnittest(maintest)
+		t.TestLib = testlib
+		t.UnitTest = maintest
+	}
+
+	// C API library, a separate target so that C++ users of the main library do not link it
+	if opts.BuildCAPI {
+		clib := denv.SetupCppLibProjectFromDir(mainpkg, capiName, capiDir)
+		clib.AddDependency(mainlib)
+		t.CLib = clib
+
+		if opts.BuildTests {
+			ctestlib := denv.SetupCppTestLibProjectFromDir(mainpkg, capiName, capiDir)
+			ctestlib.AddDependency(t.TestLib)
+
+			ctest := denv.SetupCppTestProjectFromDir(mainpkg, capiName, capiTestDir)
+			ctest.AddDependencies(opts.Deps.cunittest().GetMainLib())
+			ctest.AddDependency(ctestlib)
 
-	// unittest project
-	maintest := denv.SetupCppTestProject(mainpkg, name)
-	maintest.CopyToOutput("source/test/data", "*.bin", "data")
-	maintest.AddDependencies(cunittestpkg.GetMainLib())
-	maintest.AddDependency(testlib)
+			mainpkg.AddTestLib(ctestlib)
+			mainpkg.AddUnittest(ctest)
+			t.CTestLib = ctestlib
+			t.CUnitTest = ctest
+		}
+	}
 
-	// producer application
-	producerApp := denv.SetupCppAppProject(mainpkg, "producer", "producer")
-	producerApp.AddDependencies(centrypkg.GetMainLib())
-	producerApp.AddDependency(mainlib)
+	// applications
+	if opts.BuildApps {
+		centrypkg := opts.Deps.centry()
+		mainpkg.AddPackage(centrypkg)
+		for _, a := range selectedApps(opts) {
+			appPrj := denv.SetupCppAppProject(mainpkg, opts.AppPrefix+a.name, a.dir)
+			copyToOutput(appPrj, a.data)
+			appPrj.AddDependencies(centrypkg.GetMainLib())
+			appPrj.AddDependency(mainlib)
+			mainpkg.AddMainApp(appPrj)
+			t.Apps[a.name] = appPrj
+		}
+	}
 
-	mainpkg.AddMainApp(producerApp)
 	mainpkg.AddMainLib(mainlib)
-	mainpkg.AddTestLib(testlib)
-	mainpkg.AddUnittest(maintest)
-	return mainpkg
+	if t.CLib != nil {
+		mainpkg.AddMainLib(t.CLib)
+	}
+	return t
 }
@@ -0,0 +1,41 @@
+package cmmio
+
+import "path/filepath"
+
+// Registry is implemented by a workspace-level package registry.
+type Registry interface {
+	Register(entry RegistryEntry) error
+}
+
+// RegistryEntry describes cmmio to a Registry.
+type RegistryEntry struct {
+	Metadata
+	Path         string    // e.g. "github.com/jurgen-kluft/cmmio"
+	Targets      *Targets  // the default package (see GetPackage) and its projects
+	Apps         []string  // application project names, in declaration order
+	Dependencies []string  // names of the dependency packages
+	Features     []Feature // see Features
+	Headers      []string  // see PublicHeaders
+}
+
+// RegisterPackage registers the default package of cmmio with registry.
+func RegisterPackage(registry Registry) error {
+	headers, err := PublicHeaders()
+	if err != nil {
+		return err
+	}
+
+	opts := DefaultOptions()
+	entry := RegistryEntry{
+		Metadata:     GetMetadata(),
+		Path:         filepath.ToSlash(repoPath()) + "/" + repoName(),
+		Targets:      GetTargets(opts),
+		Dependencies: []string{"ccore", "centry", "cunittest"},
+		Features:     Features(),
+		Headers:      headers,
+	}
+	for _, a := range selectedApps(opts) {
+		entry.Apps = append(entry.Apps, a.name)
+	}
+	return registry.Register(entry)
+}
@@ -0,0 +1,125 @@
+#include "ccore/c_target.h"
+
+#include <string.h>
+#include <stdio.h>
+#include <cstdlib>
+
+namespace ncore
+{
+    // Reads the results written by 'cmmio-bench --json', one result per line:
+    //   {"name": "throughput/16", "metric": "publish_msgs_per_s", "value": 25702580.0, "better": "higher"},
+    struct result_t
+    {
+        char   name[32];
+        char   metric[32];
+        double value;
+        bool   higher_is_better;
+    };
+
+    struct results_t
+    {
+        result_t entries[128];
+        u32      count;
+    };
+
+    static bool parse_string(const char* line, const char* key, char* dst, u32 dst_size)
+    {
+        const char* v = strstr(line, key);
+        if (v == nullptr)
+            return false;
+        v += strlen(key);
+        const char* end = strchr(v, '"');
+        if (end == nullptr || (u32)(end - v) >= dst_size)
+            return false;
+        memcpy(dst, v, end - v);
+        dst[end - v] = 0;
+        return true;
+    }
+
+    static bool load_results(const char* path, results_t& results)
+    {
+        FILE* in = fopen(path, "r");
+        if (in == nullptr)
+        {
+            printf("cmmio-bench-compare: cannot open '%s'\n", path);
+            return false;
+        }
+
+        results.count = 0;
+        char line[512];
+        while (fgets(line, sizeof(line), in) != nullptr && results.count < sizeof(results.entries) / sizeof(results.entries[0]))
+        {
+            if (strstr(line, "\"metric\":") == nullptr)
+                continue;  // the opening and closing lines
+
+            result_t&   r = results.entries[results.count];
+            char        better[8];
+            const char* value = strstr(line, "\"value\":");
+            if (!parse_string(line, "\"name\": \"", r.name, sizeof(r.name)) || !parse_string(line, "\"metric\": \"", r.metric, sizeof(r.metric)) || !parse_string(line, "\"better\": \"", better, sizeof(better)) || value == nullptr ||
+                sscanf(value + strlen("\"value\":"), " %lf", &r.value) != 1)
+            {
+                printf("cmmio-bench-compare: '%s' has a malformed result: %s", path, line);
+                fclose(in);
+                return false;
+            }
+            r.higher_is_better = strcmp(better, "higher") == 0;
+            results.count++;
+        }
+        fclose(in);
+        return true;
+    }
+
+    static const result_t* find_result(const results_t& results, const result_t& r)
+    {
+        for (u32 i = 0; i < results.count; ++i)
+        {
+            if (strcmp(results.entries[i].name, r.name) == 0 && strcmp(results.entries[i].metric, r.metric) == 0)
+                return &results.entries[i];
+        }
+        return nullptr;
+    }
+
+    static results_t s_baseline;
+    static results_t s_current;
+
+    // Compares a benchmark run against a baseline, every metric that is worse than the baseline
+    // by more than the tolerance is a regression. Exits with 1 when there is at least one.
+    int AppMain(int argc, const char** argv)
+    {
+        if (argc < 3)
+        {
+            printf("Usage: %s <baseline.json> <results.json> [tolerance_percent]\n", argv[0]);
+            printf("Both files are written by 'cmmio-bench --json <path>', the default tolerance is 10%%.\n");
+            return -1;
+        }
+        const double tolerance = (argc >= 4) ? atof(argv[3]) : 10.0;
+
+        if (!load_results(argv[1], s_baseline) || !load_results(argv[2], s_current))
+            return -1;
+
+        u32 regressions = 0;
+        u32 compared    = 0;
+        printf("%-20s %-20s %14s %14s %8s\n", "scenario", "metric", "baseline", "current", "change");
+        for (u32 i = 0; i < s_current.count; ++i)
+        {
+            const result_t& r    = s_current.entries[i];
+            const result_t* base = find_result(s_baseline, r);
+            if (base == nullptr || base->value <= 0.0)
+            {
+                printf("%-20s %-20s %14s %14.1f %8s\n", r.name, r.metric, "-", r.value, "new");
+                continue;
+            }
+
+            // positive is better, independent of the direction of the metric
+            const double change = (r.higher_is_better ? (r.value - base->value) : (base->value - r.value)) * 100.0 / base->value;
+            const bool   worse  = change < -tolerance;
+            printf("%-20s %-20s %14.1f %14.1f %+7.1f%%%s\n", r.name, r.metric, base->value, r.value, change, worse ? "  REGRESSION" : "");
+            regressions += worse ? 1 : 0;
+            compared++;
+        }
+
+        printf("cmmio-bench-compare: %u metrics compared, %u regressions (tolerance %.1f%%)\n", compared, regressions, tolerance);
+        return regressions > 0 ? 1 : 0;
+    }
+
+}  // namespace ncore
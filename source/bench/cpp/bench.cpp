@@ -0,0 +1,560 @@
+#include "ccore/c_allocator.h"
+#include "ccore/c_memory.h"
+
+#include "cmmio/c_mmmq.h"
+#include "cmmio/c_scheduler.h"
+
+#include <unistd.h>
+#include <sched.h>
+#include <signal.h>
+#include <sys/mman.h>
+#include <sys/wait.h>
+#include <string.h>
+#include <stdio.h>
+#include <cstdlib>
+#include <time.h>
+
+class malloc_based_alloc_t : public ncore::alloc_t
+{
+public:
+    void* v_allocate(ncore::u32 size, ncore::u32 alignment) { return malloc(size); }
+    void  v_deallocate(void* ptr) { free(ptr); }
+};
+
+namespace ncore
+{
+    static malloc_based_alloc_t s_malloc_based_alloc;
+    static alloc_t*             s_allocator = &s_malloc_based_alloc;
+
+    static const char* s_index_path   = "bench_index.mm";
+    static const char* s_data_path    = "bench_data.mm";
+    static const char* s_control_path = "bench_control.mm";
+    static const char* s_new_sem_name = "mmq_bench_new_entries_sem";
+    static const char* s_reg_sem_name = "mmq_bench_registry_lock_sem";
+
+    static u64 now_ns()
+    {
+        struct timespec ts;
+        clock_gettime(CLOCK_MONOTONIC, &ts);
+        return (u64)ts.tv_sec * 1000000000ull + (u64)ts.tv_nsec;
+    }
+
+    // Results written with --json, one per line:
+    //   {"name": "throughput/16", "metric": "publish_msgs_per_s", "value": 25702580.0, "better": "higher"},
+    // cmmio-bench-compare compares such a file against a baseline.
+    struct result_t
+    {
+        char   name[32];
+        char   metric[32];
+        double value;
+        bool   higher_is_better;
+    };
+
+    static result_t s_results[64];
+    static u32      s_num_results = 0;
+
+    static void add_result(const char* scenario, u32 msg_size, const char* metric, double value, bool higher_is_better)
+    {
+        if (s_num_results >= sizeof(s_results) / sizeof(s_results[0]))
+            return;
+        result_t& r = s_results[s_num_results++];
+        snprintf(r.name, sizeof(r.name), "%s/%u", scenario, msg_size);
+        snprintf(r.metric, sizeof(r.metric), "%s", metric);
+        r.value            = value;
+        r.higher_is_better = higher_is_better;
+    }
+
+    static bool write_results(const char* path, u32 msg_count)
+    {
+        FILE* out = fopen(path, "w");
+        if (out == nullptr)
+        {
+            printf("bench: cannot create '%s'\n", path);
+            return false;
+        }
+        fprintf(out, "{\"message_count\": %u, \"results\": [\n", msg_count);
+        for (u32 i = 0; i < s_num_results; ++i)
+        {
+            const result_t& r = s_results[i];
+            fprintf(out, "{\"name\": \"%s\", \"metric\": \"%s\", \"value\": %.1f, \"better\": \"%s\"}%s\n", r.name, r.metric, r.value, r.higher_is_better ? "higher" : "lower", (i + 1 < s_num_results) ? "," : "");
+        }
+        fprintf(out, "]}\n");
+        fclose(out);
+        return true;
+    }
+
+    // --samples writes every latency sample as "<message size> <nanoseconds>", one per line, for
+    // cmmio-latency-report
+    static FILE* s_samples = nullptr;
+
+    static int cmp_u64(const void* a, const void* b)
+    {
+        const u64 x = *(const u64*)a;
+        const u64 y = *(const u64*)b;
+        return (x < y) ? -1 : ((x > y) ? 1 : 0);
+    }
+
+    // Size the files up front so that neither index.mm nor data.mm has to grow during the run,
+    // the consumer maps both files only once at attach time.
+    static nmmmq::config_t make_config(u32 msg_count, u32 msg_size)
+    {
+        const uint_t index_bytes = 64 + ((uint_t)msg_count * 24);
+        const uint_t data_bytes  = 64 + ((uint_t)msg_count * (((uint_t)msg_size + 7) & ~(uint_t)7));
+        return nmmmq::config_t((index_bytes + (cMB - 1)) & ~(uint_t)(cMB - 1), (data_bytes + (cMB - 1)) & ~(uint_t)(cMB - 1), 4);
+    }
+
+    static void remove_files()
+    {
+        unlink(s_index_path);
+        unlink(s_data_path);
+        unlink(s_control_path);
+    }
+
+    static bool open_channel(nmmmq::handle_t* p, nmmmq::handle_t* c, u32 msg_count, u32 msg_size, i32& slot)
+    {
+        remove_files();
+
+        nmmmq::config_t config = make_config(msg_count, msg_size);
+
+        i32 result = nmmmq::init_producer(p, config, s_index_path, s_data_path, s_control_path, s_new_sem_name, s_reg_sem_name);
+        if (result < 0)
+        {
+            printf("bench: init producer failed (err = %s)\n", nmmmq::error_str(result));
+            return false;
+        }
+
+        result = nmmmq::attach_consumer(c, s_index_path, s_data_path, s_control_path);
+        if (result < 0)
+        {
+            printf("bench: attach consumer failed (err = %s)\n", nmmmq::error_str(result));
+            return false;
+        }
+
+        result = nmmmq::register_consumer(c, "bench", 0, slot);
+        if (result < 0)
+        {
+            printf("bench: register consumer failed (err = %s)\n", nmmmq::error_str(result));
+            return false;
+        }
+        return true;
+    }
+
+    // Publish all messages, then drain all of them; reports the rate of both phases.
+    static bool bench_throughput(u32 msg_count, u32 msg_size)
+    {
+        nmmmq::handle_t* p = nmmmq::create_handle(s_allocator);
+        nmmmq::handle_t* c = nmmmq::create_handle(s_allocator);
+
+        i32  slot = -1;
+        bool ok   = open_channel(p, c, msg_count, msg_size, slot);
+        if (ok)
+        {
+            u8* msg = (u8*)malloc(msg_size);
+            memset(msg, 0xCD, msg_size);
+
+            const u64 publish_begin = now_ns();
+            for (u32 i = 0; i < msg_count && ok; ++i)
+            {
+                *(u32*)msg = i;
+                ok         = nmmmq::publish(p, msg, msg_size) >= 0;
+            }
+            const u64 publish_end = now_ns();
+
+            u32       drained     = 0;
+            const u8* msg_data    = nullptr;
+            u32       msg_len     = 0;
+            const u64 drain_begin = now_ns();
+            while (nmmmq::consumer_drain(c, slot, msg_data, msg_len))
+                drained++;
+            const u64 drain_end = now_ns();
+
+            free(msg);
+
+            if (!ok || drained != msg_count)
+            {
+                printf("bench: throughput run failed (published ok = %d, drained %u of %u)\n", ok ? 1 : 0, drained, msg_count);
+                ok = false;
+            }
+            else
+            {
+                const double publish_s = (double)(publish_end - publish_begin) / 1e9;
+                const double drain_s   = (double)(drain_end - drain_begin) / 1e9;
+                const double mb        = ((double)msg_count * (double)msg_size) / (1024.0 * 1024.0);
+                printf("throughput  %6u bytes x %8u: publish %12.0f msg/s %9.1f MB/s, drain %12.0f msg/s %9.1f MB/s\n", msg_size, msg_count, msg_count / publish_s, mb / publish_s, msg_count / drain_s, mb / drain_s);
+                add_result("throughput", msg_size, "publish_msgs_per_s", msg_count / publish_s, true);
+                add_result("throughput", msg_size, "drain_msgs_per_s", msg_count / drain_s, true);
+            }
+        }
+
+        nmmmq::destroy_handle(c);
+        nmmmq::destroy_handle(p);
+        remove_files();
+        return ok;
+    }
+
+    // Same as bench_throughput, but publishes and drains in batches of @batch_size messages.
+    static bool bench_batch(u32 msg_count, u32 msg_size, u32 batch_size)
+    {
+        nmmmq::handle_t* p = nmmmq::create_handle(s_allocator);
+        nmmmq::handle_t* c = nmmmq::create_handle(s_allocator);
+
+        i32  slot = -1;
+        bool ok   = open_channel(p, c, msg_count, msg_size, slot);
+        if (ok)
+        {
+            u8*          msgs     = (u8*)malloc((size_t)msg_size * batch_size);
+            const void** msg_ptrs = (const void**)malloc(sizeof(void*) * batch_size);
+            u32*         msg_lens = (u32*)malloc(sizeof(u32) * batch_size);
+            u8 const**   msg_data = (u8 const**)malloc(sizeof(u8*) * batch_size);
+            memset(msgs, 0xCD, (size_t)msg_size * batch_size);
+            for (u32 i = 0; i < batch_size; ++i)
+            {
+                msg_ptrs[i] = msgs + (size_t)i * msg_size;
+                msg_lens[i] = msg_size;
+            }
+
+            const u64 publish_begin = now_ns();
+            for (u32 i = 0; i < msg_count && ok; i += batch_size)
+            {
+                const u32 n = (msg_count - i < batch_size) ? (msg_count - i) : batch_size;
+                ok          = nmmmq::publish_batch(p, msg_ptrs, msg_lens, n) >= 0;
+            }
+            const u64 publish_end = now_ns();
+
+            u32       drained     = 0;
+            const u64 drain_begin = now_ns();
+            for (u32 n; (n = nmmmq::consumer_drain_batch(c, slot, msg_data, msg_lens, batch_size)) > 0;)
+                drained += n;
+            const u64 drain_end = now_ns();
+
+            free(msg_data);
+            free(msg_lens);
+            free(msg_ptrs);
+            free(msgs);
+
+            if (!ok || drained != msg_count)
+            {
+                printf("bench: batch run failed (published ok = %d, drained %u of %u)\n", ok ? 1 : 0, drained, msg_count);
+                ok = false;
+            }
+            else
+            {
+                const double publish_s = (double)(publish_end - publish_begin) / 1e9;
+                const double drain_s   = (double)(drain_end - drain_begin) / 1e9;
+                const double mb        = ((double)msg_count * (double)msg_size) / (1024.0 * 1024.0);
+                printf("batch %4u  %6u bytes x %8u: publish %12.0f msg/s %9.1f MB/s, drain %12.0f msg/s %9.1f MB/s\n", batch_size, msg_size, msg_count, msg_count / publish_s, mb / publish_s, msg_count / drain_s, mb / drain_s);
+                add_result("batch", msg_size, "publish_msgs_per_s", msg_count / publish_s, true);
+                add_result("batch", msg_size, "drain_msgs_per_s", msg_count / drain_s, true);
+            }
+        }
+
+        nmmmq::destroy_handle(c);
+        nmmmq::destroy_handle(p);
+        remove_files();
+        return ok;
+    }
+
+    // Publish a single message and drain it immediately, measuring the publish->drain round trip.
+    static bool bench_latency(u32 msg_count, u32 msg_size)
+    {
+        nmmmq::handle_t* p = nmmmq::create_handle(s_allocator);
+        nmmmq::handle_t* c = nmmmq::create_handle(s_allocator);
+
+        i32  slot = -1;
+        bool ok   = open_channel(p, c, msg_count, msg_size, slot);
+        if (ok)
+        {
+            u8*  msg     = (u8*)malloc(msg_size);
+            u64* samples = (u64*)malloc(sizeof(u64) * msg_count);
+            memset(msg, 0xCD, msg_size);
+
+            const u8* msg_data = nullptr;
+            u32       msg_len  = 0;
+            for (u32 i = 0; i < msg_count && ok; ++i)
+            {
+                const u64 begin = now_ns();
+                ok              = nmmmq::publish(p, msg, msg_size) >= 0 && nmmmq::consumer_drain(c, slot, msg_data, msg_len);
+                samples[i]      = now_ns() - begin;
+            }
+
+            if (!ok)
+            {
+                printf("bench: latency run failed\n");
+            }
+            else
+            {
+                qsort(samples, msg_count, sizeof(u64), cmp_u64);
+                printf("latency     %6u bytes x %8u: min %6llu ns, p50 %6llu ns, p99 %6llu ns, p99.9 %6llu ns, max %8llu ns\n", msg_size, msg_count, (unsigned long long)samples[0], (unsigned long long)samples[msg_count / 2],
+                       (unsigned long long)samples[(u64)msg_count * 99 / 100], (unsigned long long)samples[(u64)msg_count * 999 / 1000], (unsigned long long)samples[msg_count - 1]);
+                add_result("latency", msg_size, "p50_ns", (double)samples[msg_count / 2], false);
+                add_result("latency", msg_size, "p99_ns", (double)samples[(u64)msg_count * 99 / 100], false);
+                for (u32 i = 0; s_samples != nullptr && i < msg_count; ++i)
+                    fprintf(s_samples, "%u %llu\n", msg_size, (unsigned long long)samples[i]);
+            }
+
+            free(samples);
+            free(msg);
+        }
+
+        nmmmq::destroy_handle(c);
+        nmmmq::destroy_handle(p);
+        remove_files();
+        return ok;
+    }
+
+    // A chatty channel with @msg_count messages and a quiet one with 1 message for every 100,
+    // drained through a scheduler with equal weights. Reports the drain rate and after how much
+    // of the run (in percent of all messages) the quiet channel was done, 100 would mean starved.
+    static bool bench_scheduler(u32 msg_count, u32 msg_size)
+    {
+        const char* quiet_index_path   = "bench_quiet_index.mm";
+        const char* quiet_data_path    = "bench_quiet_data.mm";
+        const char* quiet_control_path = "bench_quiet_control.mm";
+        const u32   quiet_count        = (msg_count >= 100) ? msg_count / 100 : 1;
+
+        nmmmq::handle_t* p  = nmmmq::create_handle(s_allocator);
+        nmmmq::handle_t* c  = nmmmq::create_handle(s_allocator);
+        nmmmq::handle_t* qp = nmmmq::create_handle(s_allocator);
+        nmmmq::handle_t* qc = nmmmq::create_handle(s_allocator);
+
+        i32  slot = -1, quiet_slot = -1;
+        bool ok   = open_channel(p, c, msg_count, msg_size, slot);
+        if (ok)
+        {
+            nmmmq::config_t config = make_config(quiet_count, msg_size);
+            ok                     = nmmmq::init_producer(qp, config, quiet_index_path, quiet_data_path, quiet_control_path, "mmq_bench_quiet_new_sem", "mmq_bench_quiet_reg_sem") >= 0;
+            ok                     = ok && nmmmq::attach_consumer(qc, quiet_index_path, quiet_data_path, quiet_control_path) >= 0;
+            ok                     = ok && nmmmq::register_consumer(qc, "bench", 0, quiet_slot) >= 0;
+            if (!ok)
+                printf("bench: cannot open the quiet channel\n");
+        }
+
+        nscheduler::scheduler_t* sched = nscheduler::create_scheduler(s_allocator, 2);
+        if (ok)
+        {
+            u8* msg = (u8*)malloc(msg_size);
+            memset(msg, 0xCD, msg_size);
+            for (u32 i = 0; i < msg_count && ok; ++i)
+                ok = nmmmq::publish(p, msg, msg_size) >= 0;
+            for (u32 i = 0; i < quiet_count && ok; ++i)
+                ok = nmmmq::publish(qp, msg, msg_size) >= 0;
+            free(msg);
+
+            nscheduler::add_channel(sched, c, slot, 1);
+            nscheduler::add_channel(sched, qc, quiet_slot, 1);
+
+            u32       drained = 0, quiet_drained = 0, quiet_done = 0;
+            i32       channel  = -1;
+            const u8* msg_data = nullptr;
+            u32       msg_len  = 0;
+            const u64 begin    = now_ns();
+            while (nscheduler::next(sched, channel, msg_data, msg_len))
+            {
+                drained++;
+                if (channel == 1 && ++quiet_drained == quiet_count)
+                    quiet_done = drained;
+            }
+            const u64 end = now_ns();
+
+            if (!ok || drained != msg_count + quiet_count)
+            {
+                printf("bench: scheduler run failed (published ok = %d, drained %u of %u)\n", ok ? 1 : 0, drained, msg_count + quiet_count);
+                ok = false;
+            }
+            else
+            {
+                const double drain_s  = (double)(end - begin) / 1e9;
+                const double done_pct = (double)quiet_done * 100.0 / (double)drained;
+                printf("scheduler   %6u bytes x %8u: drain %12.0f msg/s, quiet channel (%u messages) done after %.1f%% of the run\n", msg_size, msg_count, drained / drain_s, quiet_count, done_pct);
+                add_result("scheduler", msg_size, "drain_msgs_per_s", drained / drain_s, true);
+                add_result("scheduler", msg_size, "quiet_done_pct", done_pct, false);
+            }
+        }
+        nscheduler::destroy_scheduler(sched);
+
+        nmmmq::destroy_handle(qc);
+        nmmmq::destroy_handle(qp);
+        nmmmq::destroy_handle(c);
+        nmmmq::destroy_handle(p);
+        unlink(quiet_index_path);
+        unlink(quiet_data_path);
+        unlink(quiet_control_path);
+        remove_files();
+        return ok;
+    }
+
+    // How the consumer in bench_wakeup waits for the next message
+    enum wait_strategy_t
+    {
+        WAIT_SPIN,        // poll consumer_drain in a tight loop
+        WAIT_SPIN_YIELD,  // poll, but give up the cpu after every failed poll
+        WAIT_BLOCK,       // sleep in wait_for_new until the producer posts the semaphore
+    };
+
+    static const char* wait_strategy_name(wait_strategy_t strategy)
+    {
+        switch (strategy)
+        {
+            case WAIT_SPIN: return "wakeup-spin";
+            case WAIT_SPIN_YIELD: return "wakeup-yield";
+            case WAIT_BLOCK: return "wakeup-block";
+        }
+        return "wakeup";
+    }
+
+    // Measures the time from publish until a consumer in another process holds the message, for
+    // one wait strategy. Every message carries its publish time, the producer only publishes the
+    // next one after the consumer acknowledged the previous one, so each sample is a full wakeup.
+    static bool bench_wakeup(u32 msg_count, u32 msg_size, wait_strategy_t strategy)
+    {
+        nmmmq::handle_t* p = nmmmq::create_handle(s_allocator);
+        nmmmq::handle_t* c = nmmmq::create_handle(s_allocator);
+
+        i32  slot = -1;
+        bool ok   = open_channel(p, c, msg_count, msg_size, slot);
+
+        // samples[0] counts the acknowledged messages, the samples follow it
+        const size_t shared_size = sizeof(u64) * ((size_t)msg_count + 1);
+        u64*         shared      = ok ? (u64*)mmap(nullptr, shared_size, PROT_READ | PROT_WRITE, MAP_SHARED | MAP_ANON, -1, 0) : (u64*)MAP_FAILED;
+        ok                       = ok && shared != (u64*)MAP_FAILED;
+
+        const pid_t child = ok ? fork() : (pid_t)-1;
+        if (child == 0)
+        {
+            volatile u64* acked    = shared;
+            u64*          samples  = shared + 1;
+            const u8*     msg_data = nullptr;
+            u32           msg_len  = 0;
+            for (u32 i = 0; i < msg_count; ++i)
+            {
+                if (strategy == WAIT_BLOCK)
+                {
+                    while (!nmmmq::wait_for_new(c)) {}
+                }
+                while (!nmmmq::consumer_drain(c, slot, msg_data, msg_len))
+                {
+                    if (strategy == WAIT_SPIN_YIELD)
+                        sched_yield();
+                }
+                samples[i] = now_ns() - *(const u64*)msg_data;
+                // the polling strategies still take the post of each message, the semaphore would
+                // otherwise overflow on long runs
+                if (strategy != WAIT_BLOCK)
+                    nmmmq::wait_for_new(c);
+                __atomic_store_n(acked, (u64)(i + 1), __ATOMIC_RELEASE);
+            }
+            _exit(0);
+        }
+        ok = ok && child > 0;
+
+        if (ok)
+        {
+            u8* msg = (u8*)malloc(msg_size);
+            memset(msg, 0xCD, msg_size);
+            int  status = 0;
+            bool exited = false;
+            for (u32 i = 0; i < msg_count && ok; ++i)
+            {
+                *(u64*)msg = now_ns();
+                ok         = nmmmq::publish(p, msg, msg_size) >= 0;
+                // yield while waiting for the acknowledgement so that the consumer also gets the
+                // cpu on a single core machine, stop when the consumer exited without it
+                while (ok && !exited && __atomic_load_n(shared, __ATOMIC_ACQUIRE) <= i)
+                {
+                    sched_yield();
+                    exited = waitpid(child, &status, WNOHANG) == child;
+                    ok     = !exited || __atomic_load_n(shared, __ATOMIC_ACQUIRE) > i;
+                }
+            }
+            free(msg);
+
+            if (!ok && !exited)
+                kill(child, SIGKILL);
+            if (!exited)
+                waitpid(child, &status, 0);
+            ok = ok && WIFEXITED(status) && WEXITSTATUS(status) == 0;
+        }
+
+        if (!ok)
+        {
+            printf("bench: %s run failed\n", wait_strategy_name(strategy));
+        }
+        else
+        {
+            u64* samples = shared + 1;
+            qsort(samples, msg_count, sizeof(u64), cmp_u64);
+            printf("%-12s%6u bytes x %8u: min %6llu ns, p50 %6llu ns, p99 %6llu ns, p99.9 %6llu ns, max %8llu ns\n", wait_strategy_name(strategy), msg_size, msg_count, (unsigned long long)samples[0], (unsigned long long)samples[msg_count / 2],
+                   (unsigned long long)samples[(u64)msg_count * 99 / 100], (unsigned long long)samples[(u64)msg_count * 999 / 1000], (unsigned long long)samples[msg_count - 1]);
+            add_result(wait_strategy_name(strategy), msg_size, "p50_ns", (double)samples[msg_count / 2], false);
+            add_result(wait_strategy_name(strategy), msg_size, "p99_ns", (double)samples[(u64)msg_count * 99 / 100], false);
+        }
+
+        if (shared != (u64*)MAP_FAILED)
+            munmap(shared, shared_size);
+        nmmmq::destroy_handle(c);
+        nmmmq::destroy_handle(p);
+        remove_files();
+        return ok;
+    }
+
+    int AppMain(int argc, const char** argv)
+    {
+        u32         msg_count    = 100000;
+        const char* json_path    = nullptr;
+        const char* samples_path = nullptr;
+        for (int i = 1; i < argc; ++i)
+        {
+            if (strcmp(argv[i], "--json") == 0 && i + 1 < argc)
+                json_path = argv[++i];
+            else if (strcmp(argv[i], "--samples") == 0 && i + 1 < argc)
+                samples_path = argv[++i];
+            else
+                msg_count = (u32)atoi(argv[i]);
+        }
+        if (msg_count == 0)
+        {
+            printf("Usage: %s [--json <results.json>] [--samples <samples.txt>] [message_count]\n", argv[0]);
+            return -1;
+        }
+        if (samples_path != nullptr && (s_samples = fopen(samples_path, "w")) == nullptr)
+        {
+            printf("bench: cannot create '%s'\n", samples_path);
+            return -1;
+        }
+
+        const u32 msg_sizes[] = {16, 64, 256, 1024};
+        for (u32 i = 0; i < sizeof(msg_sizes) / sizeof(msg_sizes[0]); ++i)
+        {
+            if (!bench_throughput(msg_count, msg_sizes[i]))
+                return -1;
+        }
+        for (u32 i = 0; i < sizeof(msg_sizes) / sizeof(msg_sizes[0]); ++i)
+        {
+            if (!bench_batch(msg_count, msg_sizes[i], 64))
+                return -1;
+        }
+        for (u32 i = 0; i < sizeof(msg_sizes) / sizeof(msg_sizes[0]); ++i)
+        {
+            if (!bench_latency(msg_count, msg_sizes[i]))
+                return -1;
+        }
+
+        if (!bench_scheduler(msg_count, 64))
+            return -1;
+
+        // a wakeup costs microseconds instead of nanoseconds, fewer messages are enough
+        const u32             wakeup_count = (msg_count < 10000) ? msg_count : 10000;
+        const wait_strategy_t strategies[] = {WAIT_SPIN, WAIT_SPIN_YIELD, WAIT_BLOCK};
+        for (u32 i = 0; i < sizeof(strategies) / sizeof(strategies[0]); ++i)
+        {
+            if (!bench_wakeup(wakeup_count, 64, strategies[i]))
+                return -1;
+        }
+        if (s_samples != nullptr)
+            fclose(s_samples);
+        if (json_path != nullptr && !write_results(json_path, msg_count))
+            return -1;
+        return 0;
+    }
+
+}  // namespace ncore
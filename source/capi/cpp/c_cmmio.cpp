@@ -0,0 +1,118 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_mmio.h"
+#include "cmmio/c_mmmq.h"
+#include "cmmio/c_cmmio.h"
+
+#include <stdlib.h>
+
+namespace ncore
+{
+    // The C interface has no allocator in its signatures, everything is allocated from the heap.
+    class c_api_alloc_t : public alloc_t
+    {
+    public:
+        void* v_allocate(u32 size, u32 alignment) { return ::malloc(size); }
+        void  v_deallocate(void* ptr) { ::free(ptr); }
+    };
+
+    static c_api_alloc_t s_c_api_alloc;
+
+    static nmmio::mappedfile_t* to_mf(cmmio_mappedfile_t* mf) { return (nmmio::mappedfile_t*)mf; }
+    static nmmmq::handle_t*     to_handle(cmmio_queue_t* q) { return (nmmmq::handle_t*)q; }
+
+    static cmmio_mappedfile_t* opened_or_null(nmmio::mappedfile_t* mf, bool opened)
+    {
+        if (opened)
+            return (cmmio_mappedfile_t*)mf;
+        nmmio::deallocate(&s_c_api_alloc, mf);
+        return nullptr;
+    }
+}  // namespace ncore
+
+using namespace ncore;
+
+extern "C"
+{
+    cmmio_mappedfile_t* cmmio_mf_open_ro(const char* path)
+    {
+        nmmio::mappedfile_t* mf = nullptr;
+        nmmio::allocate(&s_c_api_alloc, mf);
+        return opened_or_null(mf, nmmio::open_ro(mf, path));
+    }
+
+    cmmio_mappedfile_t* cmmio_mf_open_rw(const char* path)
+    {
+        nmmio::mappedfile_t* mf = nullptr;
+        nmmio::allocate(&s_c_api_alloc, mf);
+        return opened_or_null(mf, nmmio::open_rw(mf, path));
+    }
+
+    cmmio_mappedfile_t* cmmio_mf_create_rw(const char* path, uint64_t size)
+    {
+        nmmio::mappedfile_t* mf = nullptr;
+        nmmio::allocate(&s_c_api_alloc, mf);
+        return opened_or_null(mf, nmmio::create_rw(mf, path, size));
+    }
+
+    void cmmio_mf_close(cmmio_mappedfile_t* mf)
+    {
+        if (mf == nullptr)
+            return;
+        nmmio::close(to_mf(mf));
+        nmmio::deallocate(&s_c_api_alloc, to_mf(mf));
+    }
+
+    int         cmmio_mf_is_writeable(cmmio_mappedfile_t* mf) { return nmmio::is_writeable(to_mf(mf)) ? 1 : 0; }
+    int         cmmio_mf_extend_size(cmmio_mappedfile_t* mf, uint64_t new_size) { return nmmio::extend_size(to_mf(mf), new_size) ? 1 : 0; }
+    void*       cmmio_mf_address_rw(cmmio_mappedfile_t* mf) { return nmmio::address_rw(to_mf(mf)); }
+    const void* cmmio_mf_address_ro(cmmio_mappedfile_t* mf) { return nmmio::address_ro(to_mf(mf)); }
+    uint64_t    cmmio_mf_size(cmmio_mappedfile_t* mf) { return nmmio::size(to_mf(mf)); }
+    void        cmmio_mf_sync(cmmio_mappedfile_t* mf) { nmmio::sync(to_mf(mf)); }
+    void        cmmio_mf_sync_range(cmmio_mappedfile_t* mf, uint64_t offset, uint64_t bytes) { nmmio::sync(to_mf(mf), offset, bytes); }
+
+    cmmio_queue_t* cmmio_mq_create(void) { return (cmmio_queue_t*)nmmmq::create_handle(&s_c_api_alloc); }
+
+    void cmmio_mq_destroy(cmmio_queue_t* q)
+    {
+        nmmmq::handle_t* h = to_handle(q);
+        nmmmq::destroy_handle(h);
+    }
+
+    int32_t cmmio_mq_init_producer(cmmio_queue_t* q, uint64_t index_bytes, uint64_t data_bytes, uint16_t max_consumers, const char* index_path, const char* data_path, const char* control_path, const char* new_sem_name, const char* reg_sem_name)
+    {
+        nmmmq::config_t config((uint_t)index_bytes, (uint_t)data_bytes, max_consumers);
+        return nmmmq::init_producer(to_handle(q), config, index_path, data_path, control_path, new_sem_name, reg_sem_name);
+    }
+
+    int32_t cmmio_mq_publish(cmmio_queue_t* q, const void* msg, uint32_t len) { return nmmmq::publish(to_handle(q), msg, len); }
+
+    int32_t cmmio_mq_attach_consumer(cmmio_queue_t* q, const char* index_path, const char* data_path, const char* control_path) { return nmmmq::attach_consumer(to_handle(q), index_path, data_path, control_path); }
+
+    int32_t cmmio_mq_register_consumer(cmmio_queue_t* q, const char* name, uint32_t start_seq, int32_t* out_slot)
+    {
+        i32       slot   = -1;
+        const i32 result = nmmmq::register_consumer(to_handle(q), name, start_seq, slot);
+        if (out_slot != nullptr)
+            *out_slot = slot;
+        return result;
+    }
+
+    int cmmio_mq_drain(cmmio_queue_t* q, int32_t slot, const uint8_t** msg_data, uint32_t* msg_len)
+    {
+        u8 const* data = nullptr;
+        u32       len  = 0;
+        const int got  = nmmmq::consumer_drain(to_handle(q), slot, data, len) ? 1 : 0;
+        if (msg_data != nullptr)
+            *msg_data = data;
+        if (msg_len != nullptr)
+            *msg_len = len;
+        return got;
+    }
+
+    int cmmio_mq_wait_for_new(cmmio_queue_t* q) { return nmmmq::wait_for_new(to_handle(q)) ? 1 : 0; }
+    int cmmio_mq_wait_for_new_timeout(cmmio_queue_t* q, uint32_t timeout_us) { return nmmmq::wait_for_new_timeout(to_handle(q), timeout_us) ? 1 : 0; }
+    int cmmio_mq_wait_for_new_cancel(cmmio_queue_t* q, uint32_t timeout_us, const volatile int32_t* cancel) { return nmmmq::wait_for_new_cancel(to_handle(q), timeout_us, cancel) ? 1 : 0; }
+
+    const char* cmmio_mq_error_str(int32_t result) { return nmmmq::error_str(result); }
+}
@@ -0,0 +1,78 @@
+#ifndef __CMMIO_C_API_H__
+#define __CMMIO_C_API_H__
+#ifdef USE_PRAGMA_ONCE
+#    pragma once
+#endif
+
+// Flat C interface over nmmio and nmmmq, intended for language bindings and C toolchains.
+// This header does not depend on ccore and can be included from C code. It belongs to the
+// cmmio_c library (source/capi), which wraps the main library.
+
+#include <stdint.h>
+
+#if defined(CMMIO_DLL)
+#    if defined(_WIN32)
+#        if defined(CMMIO_EXPORTS)
+#            define CMMIO_C_API __declspec(dllexport)
+#        else
+#            define CMMIO_C_API __declspec(dllimport)
+#        endif
+#    else
+#        define CMMIO_C_API __attribute__((visibility("default")))
+#    endif
+#else
+#    define CMMIO_C_API
+#endif
+
+#ifdef __cplusplus
+extern "C"
+{
+#endif
+
+    typedef struct cmmio_mappedfile_s cmmio_mappedfile_t;
+    typedef struct cmmio_queue_s      cmmio_queue_t;
+
+    // ====== Memory mapped files ======
+
+    // All open/create functions return NULL on failure.
+    CMMIO_C_API cmmio_mappedfile_t* cmmio_mf_open_ro(const char* path);
+    CMMIO_C_API cmmio_mappedfile_t* cmmio_mf_open_rw(const char* path);
+    CMMIO_C_API cmmio_mappedfile_t* cmmio_mf_create_rw(const char* path, uint64_t size);
+    CMMIO_C_API void                cmmio_mf_close(cmmio_mappedfile_t* mf);
+
+    CMMIO_C_API int         cmmio_mf_is_writeable(cmmio_mappedfile_t* mf);
+    CMMIO_C_API int         cmmio_mf_extend_size(cmmio_mappedfile_t* mf, uint64_t new_size);
+    CMMIO_C_API void*       cmmio_mf_address_rw(cmmio_mappedfile_t* mf);
+    CMMIO_C_API const void* cmmio_mf_address_ro(cmmio_mappedfile_t* mf);
+    CMMIO_C_API uint64_t    cmmio_mf_size(cmmio_mappedfile_t* mf);
+    CMMIO_C_API void        cmmio_mf_sync(cmmio_mappedfile_t* mf);
+    CMMIO_C_API void        cmmio_mf_sync_range(cmmio_mappedfile_t* mf, uint64_t offset, uint64_t bytes);
+
+    // ====== Message queue ======
+    // Functions returning int32_t return 0 on success and a negative error code otherwise,
+    // use cmmio_mq_error_str() to get a description.
+
+    CMMIO_C_API cmmio_queue_t* cmmio_mq_create(void);
+    CMMIO_C_API void           cmmio_mq_destroy(cmmio_queue_t* q);
+
+    CMMIO_C_API int32_t cmmio_mq_init_producer(cmmio_queue_t* q, uint64_t index_bytes, uint64_t data_bytes, uint16_t max_consumers, const char* index_path, const char* data_path, const char* control_path, const char* new_sem_name,
+                                               const char* reg_sem_name);
+    CMMIO_C_API int32_t cmmio_mq_publish(cmmio_queue_t* q, const void* msg, uint32_t len);
+
+    CMMIO_C_API int32_t cmmio_mq_attach_consumer(cmmio_queue_t* q, const char* index_path, const char* data_path, const char* control_path);
+    CMMIO_C_API int32_t cmmio_mq_register_consumer(cmmio_queue_t* q, const char* name, uint32_t start_seq, int32_t* out_slot);
+
+    // Returns 1 and sets msg_data/msg_len when a message was available, 0 otherwise.
+    CMMIO_C_API int cmmio_mq_drain(cmmio_queue_t* q, int32_t slot, const uint8_t** msg_data, uint32_t* msg_len);
+    CMMIO_C_API int cmmio_mq_wait_for_new(cmmio_queue_t* q);
+    CMMIO_C_API int cmmio_mq_wait_for_new_timeout(cmmio_queue_t* q, uint32_t timeout_us);
+    // Returns 0 with errno = ECANCELED as soon as *cancel is non-zero.
+    CMMIO_C_API int cmmio_mq_wait_for_new_cancel(cmmio_queue_t* q, uint32_t timeout_us, const volatile int32_t* cancel);
+
+    CMMIO_C_API const char* cmmio_mq_error_str(int32_t result);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif  // __CMMIO_C_API_H__
@@ -0,0 +1,99 @@
+#include "ccore/c_target.h"
+
+#include "cmmio/c_cmmio.h"
+
+#include "cunittest/cunittest.h"
+
+#include <string.h>
+#include <unistd.h>
+
+UNITTEST_SUITE_BEGIN(cmmio_c)
+{
+    UNITTEST_FIXTURE(mappedfile)
+    {
+        UNITTEST_FIXTURE_SETUP() {}
+        UNITTEST_FIXTURE_TEARDOWN() {}
+
+        UNITTEST_TEST(open_nonexistent_file)
+        {
+            cmmio_mappedfile_t* mf = cmmio_mf_open_ro("this_file_does_not_exist.txt");
+            CHECK_NULL(mf);
+        }
+
+        UNITTEST_TEST(create_write_reopen)
+        {
+            const char* path = "test_cmmio_c.mm";
+
+            cmmio_mappedfile_t* mf = cmmio_mf_create_rw(path, 4096);
+            CHECK_NOT_NULL(mf);
+            CHECK_EQUAL(1, cmmio_mf_is_writeable(mf));
+            CHECK_EQUAL((uint64_t)4096, cmmio_mf_size(mf));
+            memcpy(cmmio_mf_address_rw(mf), "cmmio", 6);
+            cmmio_mf_sync(mf);
+            cmmio_mf_close(mf);
+
+            mf = cmmio_mf_open_ro(path);
+            CHECK_NOT_NULL(mf);
+            CHECK_EQUAL(0, cmmio_mf_is_writeable(mf));
+            CHECK_NULL(cmmio_mf_address_rw(mf));
+            CHECK_EQUAL(0, memcmp(cmmio_mf_address_ro(mf), "cmmio", 6));
+            cmmio_mf_close(mf);
+
+            unlink(path);
+        }
+    }
+
+    UNITTEST_FIXTURE(queue)
+    {
+        UNITTEST_FIXTURE_SETUP() {}
+        UNITTEST_FIXTURE_TEARDOWN() {}
+
+        UNITTEST_TEST(create_destroy)
+        {
+            cmmio_queue_t* q = cmmio_mq_create();
+            CHECK_NOT_NULL(q);
+            cmmio_mq_destroy(q);
+        }
+
+        UNITTEST_TEST(publish_drain)
+        {
+            const char* index_path   = "test_cmmio_c_index.mm";
+            const char* data_path    = "test_cmmio_c_data.mm";
+            const char* control_path = "test_cmmio_c_control.mm";
+
+            cmmio_queue_t* p = cmmio_mq_create();
+            CHECK_EQUAL(0, cmmio_mq_init_producer(p, 64 * 1024, 64 * 1024, 4, index_path, data_path, control_path, "test_cmmio_c_new_sem", "test_cmmio_c_reg_sem"));
+
+            cmmio_queue_t* c = cmmio_mq_create();
+            CHECK_EQUAL(0, cmmio_mq_attach_consumer(c, index_path, data_path, control_path));
+
+            int32_t slot = -1;
+            CHECK_EQUAL(0, cmmio_mq_register_consumer(c, "test", 0, &slot));
+            CHECK_EQUAL(0, slot);
+
+            const uint8_t* msg_data = NULL;
+            uint32_t       msg_len  = 0;
+            CHECK_EQUAL(0, cmmio_mq_drain(c, slot, &msg_data, &msg_len));
+
+            CHECK_EQUAL(0, cmmio_mq_publish(p, "hello", 6));
+            CHECK_EQUAL(1, cmmio_mq_drain(c, slot, &msg_data, &msg_len));
+            CHECK_EQUAL((uint32_t)6, msg_len);
+            CHECK_EQUAL(0, memcmp(msg_data, "hello", 6));
+            CHECK_EQUAL(0, cmmio_mq_drain(c, slot, &msg_data, &msg_len));
+
+            cmmio_mq_destroy(c);
+            cmmio_mq_destroy(p);
+
+            unlink(index_path);
+            unlink(data_path);
+            unlink(control_path);
+        }
+
+        UNITTEST_TEST(error_str)
+        {
+            CHECK_EQUAL(0, strcmp("Ok", cmmio_mq_error_str(0)));
+            CHECK_EQUAL(0, strcmp("Unknown error code", cmmio_mq_error_str(-1000)));
+        }
+    }
+}
+UNITTEST_SUITE_END
@@ -0,0 +1,125 @@
+#include "ccore/c_allocator.h"
+#include "ccore/c_memory.h"
+
+#include "cmmio/c_mmmq.h"
+
+#include <unistd.h>
+#include <cerrno>
+#include <string.h>
+#include <stdio.h>
+#include <cstdlib>
+
+class malloc_based_alloc_t : public ncore::alloc_t
+{
+public:
+    void* v_allocate(ncore::u32 size, ncore::u32 alignment) { return malloc(size); }
+    void  v_deallocate(void* ptr) { free(ptr); }
+};
+
+namespace ncore
+{
+
+    static malloc_based_alloc_t s_malloc_based_alloc;
+    static alloc_t*             s_allocator = &s_malloc_based_alloc;
+
+    // Consumes messages forever, or until @num_messages messages have been received when it is not 0.
+    static int consumer(const char* consumer_name, u32 start_seq, u32 num_messages)
+    {
+        nmmmq::handle_t* h = nmmmq::create_handle(s_allocator);
+
+        const char* index_path   = "index.mm";
+        const char* data_path    = "data.mm";
+        const char* control_path = "control.mm";
+
+        printf("attaching consumer '%s' with start_seq=%u to index_path=%s, data_path=%s, control_path=%s\n", consumer_name, start_seq, index_path, data_path, control_path);
+
+        i32 result = nmmmq::attach_consumer(h, index_path, data_path, control_path);
+        if (result != 0)
+        {
+            printf("consumer: attach failed (err = %s)\n", nmmmq::error_str(result));
+            nmmmq::destroy_handle(h);
+            return 1;
+        }
+
+        printf("registering consumer '%s' with start_seq=%u\n", consumer_name, start_seq);
+
+        i32 slot;
+        result = nmmmq::register_consumer(h, consumer_name, start_seq, slot);
+        if (result<0)
+        {
+            printf("consumer: register failed (err = %s)\n", nmmmq::error_str(result));
+            nmmmq::destroy_handle(h);
+            return 1;
+        }
+
+        printf("starting to consume messages...\n");
+        u32 received = 0;
+        while (num_messages == 0 || received < num_messages)
+        {
+            const u8* msg_data;
+            u32       msg_len;
+            if (!nmmmq::consumer_drain(h, slot, msg_data, msg_len))
+            {
+                if (!nmmmq::wait_for_new(h))
+                {
+                    printf("consumer: wait failed (errno=%d)\n", errno);
+                    nmmmq::destroy_handle(h);
+                    return 1;
+                }
+            }
+            else
+            {
+                // the producer demo numbers its messages "msg <seq> (pid=<pid>)"
+                char expected[32];
+                snprintf(expected, sizeof(expected), "msg %u ", start_seq + received);
+                if (msg_data == nullptr || msg_len < strlen(expected) || memcmp(msg_data, expected, strlen(expected)) != 0)
+                {
+                    printf("consumer '%s': message %u is not '%s...'\n", consumer_name, start_seq + received, expected);
+                    nmmmq::destroy_handle(h);
+                    return 1;
+                }
+                printf("consumer '%s' got message: %.*s\n", consumer_name, msg_len, msg_data);
+                received++;
+
+                usleep(80 * 1000);
+            }
+        }
+
+        nmmmq::destroy_handle(h);
+        return 0;
+    }
+
+    // Parses a non-negative decimal number, anything else (e.g. a name) is rejected.
+    static bool parse_number(const char* str, u32& value)
+    {
+        if (str[0] < '0' || str[0] > '9')
+            return false;
+        char*                    end    = nullptr;
+        const unsigned long long number = strtoull(str, &end, 10);
+        if (*end != '\0' || number > 0xFFFFFFFFull)
+            return false;
+        value = (u32)number;
+        return true;
+    }
+
+    static int usage(const char* app)
+    {
+        printf("Usage: %s [name] [start_seq] [num_messages]\n", app);
+        return -1;
+    }
+
+    int AppMain(int argc, const char** argv)
+    {
+        const char* consumer_name = (argc >= 2) ? argv[1] : "consumer1";
+        u32         start_seq     = 0;
+        u32         num_messages  = 0;
+        if (argc >= 3 && !parse_number(argv[2], start_seq))
+            return usage(argv[0]);
+        if (argc >= 4 && !parse_number(argv[3], num_messages))
+            return usage(argv[0]);
+        if (consumer(consumer_name, start_seq, num_messages) != 0)
+            return -1;
+        return 0;
+    }
+
+}  // namespace ncore
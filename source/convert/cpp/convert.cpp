@@ -0,0 +1,251 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_capture.h"
+
+#include <string.h>
+#include <stdio.h>
+#include <cstdlib>
+
+class malloc_based_alloc_t : public ncore::alloc_t
+{
+public:
+    void* v_allocate(ncore::u32 size, ncore::u32 alignment) { return malloc(size); }
+    void  v_deallocate(void* ptr) { free(ptr); }
+};
+
+namespace ncore
+{
+    static malloc_based_alloc_t s_malloc_based_alloc;
+    static alloc_t*             s_allocator = &s_malloc_based_alloc;
+
+    // Text form of a capture, one frame per line so that it can be edited and diffed:
+    //   {"version": 1, "frames": [
+    //   {"time_ns": 0, "len": 5, "text": "hello"},
+    //   {"time_ns": 1500, "len": 3, "hex": "00ff10"}
+    //   ]}
+    // Payloads that are ASCII text (optionally ending with a terminating 0) are written as "text"
+    // with JSON escapes, all others as "hex". When converting back "len" is ignored, it is taken
+    // from the payload.
+
+    static bool is_text(const u8* data, u32 len)
+    {
+        for (u32 i = 0; i < len; ++i)
+        {
+            const u8 c = data[i];
+            if ((c < 0x20 || c >= 0x7f) && c != '\n' && c != '\r' && c != '\t' && !(c == 0 && i + 1 == len))
+                return false;
+        }
+        return true;
+    }
+
+    static void write_text(FILE* out, const u8* data, u32 len)
+    {
+        for (u32 i = 0; i < len; ++i)
+        {
+            switch (data[i])
+            {
+                case '"': fputs("\\\"", out); break;
+                case '\\': fputs("\\\\", out); break;
+                case '\n': fputs("\\n", out); break;
+                case '\r': fputs("\\r", out); break;
+                case '\t': fputs("\\t", out); break;
+                case 0: fputs("\\u0000", out); break;
+                default: fputc(data[i], out); break;
+            }
+        }
+    }
+
+    static int to_text(const char* bin_path, const char* text_path)
+    {
+        ncapture::reader_t* in     = nullptr;
+        i32                 result = ncapture::open_reader(s_allocator, bin_path, in);
+        if (result != 0)
+        {
+            printf("cmmio-convert: cannot open '%s' (err = %s)\n", bin_path, ncapture::error_str(result));
+            return -1;
+        }
+
+        FILE* out = fopen(text_path, "w");
+        if (out == nullptr)
+        {
+            printf("cmmio-convert: cannot create '%s'\n", text_path);
+            ncapture::close_reader(in);
+            return -1;
+        }
+
+        fprintf(out, "{\"version\": %u, \"frames\": [\n", MMQ_CAPTURE_VERSION);
+
+        u32               count   = 0;
+        ncapture::frame_t frame;
+        const u8*         payload = nullptr;
+        while ((result = ncapture::read_frame(in, frame, payload)) == ncapture::CAPTURE_OK)
+        {
+            fprintf(out, "%s{\"time_ns\": %llu, \"len\": %u, ", count > 0 ? ",\n" : "", (unsigned long long)frame.m_time_ns, frame.m_len);
+            if (is_text(payload, frame.m_len))
+            {
+                fprintf(out, "\"text\": \"");
+                write_text(out, payload, frame.m_len);
+            }
+            else
+            {
+                fprintf(out, "\"hex\": \"");
+                for (u32 i = 0; i < frame.m_len; ++i)
+                    fprintf(out, "%02x", payload[i]);
+            }
+            fprintf(out, "\"}");
+            count++;
+        }
+        fprintf(out, "\n]}\n");
+
+        fclose(out);
+        ncapture::close_reader(in);
+        if (result != ncapture::CAPTURE_END)
+        {
+            printf("cmmio-convert: frame %u of '%s' cannot be read (err = %s)\n", count, bin_path, ncapture::error_str(result));
+            return -1;
+        }
+        printf("cmmio-convert: wrote %u frames to '%s'\n", count, text_path);
+        return 0;
+    }
+
+    static int hex_value(char c)
+    {
+        if (c >= '0' && c <= '9')
+            return c - '0';
+        if (c >= 'a' && c <= 'f')
+            return c - 'a' + 10;
+        if (c >= 'A' && c <= 'F')
+            return c - 'A' + 10;
+        return -1;
+    }
+
+    // Decodes the payload of one frame line into @payload, returns false when the line is malformed.
+    static bool parse_frame(const char* line, u64& time_ns, u8* payload, u32& len)
+    {
+        const char* t = strstr(line, "\"time_ns\":");
+        if (t == nullptr || sscanf(t + strlen("\"time_ns\":"), " %llu", (unsigned long long*)&time_ns) != 1)
+            return false;
+
+        len = 0;
+        if (const char* text = strstr(line, "\"text\": \""))
+        {
+            for (const char* c = text + strlen("\"text\": \""); *c != '"'; ++c)
+            {
+                if (*c == 0)
+                    return false;
+                if (*c != '\\')
+                {
+                    payload[len++] = (u8)*c;
+                    continue;
+                }
+                switch (*++c)
+                {
+                    case '"':
+                    case '\\':
+                    case '/': payload[len++] = (u8)*c; break;
+                    case 'n': payload[len++] = '\n'; break;
+                    case 'r': payload[len++] = '\r'; break;
+                    case 't': payload[len++] = '\t'; break;
+                    case 'u':
+                    {
+                        // only \u00XX, the text form never holds anything beyond a single byte
+                        if (c[1] != '0' || c[2] != '0' || hex_value(c[3]) < 0 || hex_value(c[4]) < 0)
+                            return false;
+                        payload[len++] = (u8)((hex_value(c[3]) << 4) | hex_value(c[4]));
+                        c += 4;
+                        break;
+                    }
+                    default: return false;
+                }
+            }
+            return true;
+        }
+        if (const char* hex = strstr(line, "\"hex\": \""))
+        {
+            for (const char* c = hex + strlen("\"hex\": \""); *c != '"'; c += 2)
+            {
+                const int hi = hex_value(c[0]);
+                const int lo = (hi >= 0) ? hex_value(c[1]) : -1;
+                if (lo < 0)
+                    return false;
+                payload[len++] = (u8)((hi << 4) | lo);
+            }
+            return true;
+        }
+        return false;
+    }
+
+    static int to_bin(const char* text_path, const char* bin_path)
+    {
+        FILE* in = fopen(text_path, "r");
+        if (in == nullptr)
+        {
+            printf("cmmio-convert: cannot open '%s'\n", text_path);
+            return -1;
+        }
+        ncapture::writer_t* out    = nullptr;
+        i32                 result = ncapture::open_writer(s_allocator, bin_path, out);
+        if (result != 0)
+        {
+            printf("cmmio-convert: cannot create '%s' (err = %s)\n", bin_path, ncapture::error_str(result));
+            fclose(in);
+            return -1;
+        }
+
+        char*  line     = nullptr;
+        size_t line_cap = 0;
+        u8*    payload  = nullptr;
+        u32    count    = 0;
+        u32    line_nr  = 0;
+        bool   ok       = true;
+        while (getline(&line, &line_cap, in) > 0)
+        {
+            line_nr++;
+            if (strstr(line, "\"time_ns\":") == nullptr)
+                continue;  // the opening and closing lines
+
+            // a payload never has more bytes than the line has characters
+            payload = (u8*)realloc(payload, line_cap);
+
+            u64 time_ns = 0;
+            u32 len     = 0;
+            if (!parse_frame(line, time_ns, payload, len))
+            {
+                printf("cmmio-convert: line %u of '%s' is not a valid frame\n", line_nr, text_path);
+                ok = false;
+                break;
+            }
+
+            result = ncapture::write_frame(out, time_ns, payload, len);
+            if (result != 0)
+            {
+                printf("cmmio-convert: writing '%s' failed (err = %s)\n", bin_path, ncapture::error_str(result));
+                ok = false;
+                break;
+            }
+            count++;
+        }
+
+        free(payload);
+        free(line);
+        ncapture::close_writer(out);
+        fclose(in);
+        if (ok)
+            printf("cmmio-convert: wrote %u frames to '%s'\n", count, bin_path);
+        return ok ? 0 : -1;
+    }
+
+    // Converts a capture recorded by cmmio-replay to its text form and back.
+    int AppMain(int argc, const char** argv)
+    {
+        if (argc >= 4 && strcmp(argv[1], "to-text") == 0)
+            return to_text(argv[2], argv[3]);
+        if (argc >= 4 && strcmp(argv[1], "to-bin") == 0)
+            return to_bin(argv[2], argv[3]);
+
+        printf("Usage: %s to-text <capture.bin> <capture.json>\n", argv[0]);
+        printf("       %s to-bin <capture.json> <capture.bin>\n", argv[0]);
+        return -1;
+    }
+
+}  // namespace ncore
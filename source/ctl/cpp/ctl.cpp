@@ -0,0 +1,274 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_mmmq.h"
+
+#include <dirent.h>
+#include <semaphore.h>
+#include <unistd.h>
+#include <string.h>
+#include <stdio.h>
+#include <cstdlib>
+
+class malloc_based_alloc_t : public ncore::alloc_t
+{
+public:
+    void* v_allocate(ncore::u32 size, ncore::u32 alignment) { return malloc(size); }
+    void  v_deallocate(void* ptr) { free(ptr); }
+};
+
+namespace ncore
+{
+    static malloc_based_alloc_t s_malloc_based_alloc;
+    static alloc_t*             s_allocator = &s_malloc_based_alloc;
+
+    // A channel is identified by the prefix of its files, <prefix>index.mm, <prefix>data.mm and
+    // <prefix>control.mm, the empty prefix ("") is the channel of the producer demo.
+    struct channel_t
+    {
+        char index_path[256];
+        char data_path[256];
+        char control_path[256];
+    };
+
+    static void channel_paths(channel_t& c, const char* dir, const char* prefix)
+    {
+        snprintf(c.index_path, sizeof(c.index_path), "%s/%sindex.mm", dir, prefix);
+        snprintf(c.data_path, sizeof(c.data_path), "%s/%sdata.mm", dir, prefix);
+        snprintf(c.control_path, sizeof(c.control_path), "%s/%scontrol.mm", dir, prefix);
+    }
+
+    static i32 inspect_channel(const channel_t& c, nmmmq::info_t& info, i32& active)
+    {
+        nmmmq::handle_t* h      = nmmmq::create_handle(s_allocator);
+        i32              result = nmmmq::attach_inspector(h, c.index_path, c.data_path, c.control_path);
+        if (result == 0)
+            result = nmmmq::inspect(h, info);
+
+        active = 0;
+        for (i32 i = 0; result == 0 && i < (i32)info.max_consumers; ++i)
+        {
+            nmmmq::consumer_info_t ci;
+            if (nmmmq::inspect_consumer(h, i, ci) == 0 && ci.active)
+                active++;
+        }
+
+        nmmmq::destroy_handle(h);
+        return result;
+    }
+
+    static bool has_suffix(const char* str, const char* suffix)
+    {
+        const size_t str_len    = strlen(str);
+        const size_t suffix_len = strlen(suffix);
+        return str_len >= suffix_len && strcmp(str + str_len - suffix_len, suffix) == 0;
+    }
+
+    static int cmd_list(const char* dir)
+    {
+        DIR* d = opendir(dir);
+        if (d == nullptr)
+        {
+            printf("cmmio-ctl: cannot open directory '%s'\n", dir);
+            return -1;
+        }
+
+        printf("%-24s %10s %12s %10s %10s\n", "channel", "next_seq", "entries", "data %", "consumers");
+        struct dirent* e;
+        while ((e = readdir(d)) != nullptr)
+        {
+            if (!has_suffix(e->d_name, "index.mm"))
+                continue;
+
+            char prefix[256];
+            snprintf(prefix, sizeof(prefix), "%.*s", (int)(strlen(e->d_name) - strlen("index.mm")), e->d_name);
+
+            channel_t c;
+            channel_paths(c, dir, prefix);
+            if (access(c.data_path, F_OK) != 0 || access(c.control_path, F_OK) != 0)
+                continue;
+
+            nmmmq::info_t info;
+            i32           active = 0;
+            const i32     result = inspect_channel(c, info, active);
+            if (result != 0)
+            {
+                printf("%-24s %s\n", prefix[0] ? prefix : "\"\"", nmmmq::error_str(result));
+                continue;
+            }
+
+            const double used = info.data_capacity > 0 ? (100.0 * (double)info.data_write_pos / (double)info.data_capacity) : 0.0;
+            printf("%-24s %10llu %12llu %9.1f%% %6d/%-3u\n", prefix[0] ? prefix : "\"\"", (unsigned long long)info.next_seq, (unsigned long long)info.index_capacity, used, active, info.max_consumers);
+        }
+        closedir(d);
+        return 0;
+    }
+
+    static int cmd_create(const char* prefix, u32 index_kb, u32 data_kb, u32 max_consumers)
+    {
+        channel_t c;
+        channel_paths(c, ".", prefix);
+        if (access(c.index_path, F_OK) == 0 || access(c.data_path, F_OK) == 0 || access(c.control_path, F_OK) == 0)
+        {
+            printf("cmmio-ctl: channel '%s' already exists\n", prefix);
+            return -1;
+        }
+
+        char new_sem_name[52];
+        char reg_sem_name[52];
+        snprintf(new_sem_name, sizeof(new_sem_name), "%smmq_new_entries_sem", prefix);
+        snprintf(reg_sem_name, sizeof(reg_sem_name), "%smmq_registry_lock_sem", prefix);
+
+        nmmmq::handle_t* h = nmmmq::create_handle(s_allocator);
+        nmmmq::config_t  config((uint_t)index_kb * cKB, (uint_t)data_kb * cKB, (u16)max_consumers);
+        const i32        result = nmmmq::init_producer(h, config, c.index_path, c.data_path, c.control_path, new_sem_name, reg_sem_name);
+        nmmmq::destroy_handle(h);
+
+        if (result != 0)
+        {
+            printf("cmmio-ctl: create failed (err = %s)\n", nmmmq::error_str(result));
+            return -1;
+        }
+        printf("cmmio-ctl: created channel '%s' (index %u KiB, data %u KiB, %u consumers)\n", prefix, index_kb, data_kb, max_consumers);
+        return 0;
+    }
+
+    static int cmd_destroy(const char* prefix)
+    {
+        channel_t c;
+        channel_paths(c, ".", prefix);
+
+        // the semaphore names are stored in control.mm, a channel that cannot be inspected
+        // anymore only has its files removed
+        nmmmq::info_t info;
+        i32           active = 0;
+        if (inspect_channel(c, info, active) == 0)
+        {
+            if (active > 0)
+                printf("cmmio-ctl: warning, channel '%s' still has %d registered consumers\n", prefix, active);
+            sem_unlink(info.new_entries_sem);
+            sem_unlink(info.registry_lock_sem);
+        }
+
+        const bool removed = unlink(c.index_path) == 0;
+        unlink(c.data_path);
+        unlink(c.control_path);
+        if (!removed)
+        {
+            printf("cmmio-ctl: channel '%s' does not exist\n", prefix);
+            return -1;
+        }
+        printf("cmmio-ctl: destroyed channel '%s'\n", prefix);
+        return 0;
+    }
+
+    static int cmd_resize(const char* prefix, u32 index_kb, u32 data_kb)
+    {
+        channel_t c;
+        channel_paths(c, ".", prefix);
+
+        nmmmq::info_t info;
+        i32           active = 0;
+        i32           result = inspect_channel(c, info, active);
+        if (result != 0)
+        {
+            printf("cmmio-ctl: cannot inspect channel '%s' (err = %s)\n", prefix, nmmmq::error_str(result));
+            return -1;
+        }
+
+        // opening the channel as producer re-initializes control.mm, consumers have to register again
+        if (active > 0)
+            printf("cmmio-ctl: warning, dropping %d registered consumers of channel '%s'\n", active, prefix);
+
+        nmmmq::handle_t* h = nmmmq::create_handle(s_allocator);
+        nmmmq::config_t  config((uint_t)index_kb * cKB, (uint_t)data_kb * cKB, info.max_consumers);
+        result = nmmmq::init_producer(h, config, c.index_path, c.data_path, c.control_path, info.new_entries_sem, info.registry_lock_sem);
+        if (result == 0)
+            result = nmmmq::reserve(h, (uint_t)index_kb * cKB, (uint_t)data_kb * cKB);
+        nmmmq::destroy_handle(h);
+
+        if (result != 0)
+        {
+            printf("cmmio-ctl: resize failed (err = %s)\n", nmmmq::error_str(result));
+            return -1;
+        }
+        printf("cmmio-ctl: channel '%s' is now at least index %u KiB, data %u KiB\n", prefix, index_kb, data_kb);
+        return 0;
+    }
+
+    static int cmd_release(const char* prefix, i32 slot)
+    {
+        channel_t c;
+        channel_paths(c, ".", prefix);
+
+        nmmmq::handle_t* h      = nmmmq::create_handle(s_allocator);
+        i32              result = nmmmq::attach_consumer(h, c.index_path, c.data_path, c.control_path);
+
+        nmmmq::consumer_info_t ci;
+        if (result == 0)
+            result = nmmmq::inspect_consumer(h, slot, ci);
+        if (result == 0)
+            result = nmmmq::release_consumer(h, slot);
+        nmmmq::destroy_handle(h);
+
+        if (result != 0)
+        {
+            printf("cmmio-ctl: release failed (err = %s)\n", nmmmq::error_str(result));
+            return -1;
+        }
+        if (ci.active)
+            printf("cmmio-ctl: released slot %d ('%s', last_seq %llu) of channel '%s'\n", slot, ci.name, (unsigned long long)ci.last_seq, prefix);
+        else
+            printf("cmmio-ctl: slot %d of channel '%s' was not in use\n", slot, prefix);
+        return 0;
+    }
+
+    static int usage(const char* app)
+    {
+        printf("Usage: %s list [dir]\n", app);
+        printf("       %s create <prefix> [index_kb] [data_kb] [max_consumers]\n", app);
+        printf("       %s destroy <prefix>\n", app);
+        printf("       %s resize <prefix> <index_kb> <data_kb>\n", app);
+        printf("       %s release <prefix> <slot>\n", app);
+        printf("A channel consists of <prefix>index.mm, <prefix>data.mm and <prefix>control.mm in the\n");
+        printf("working directory, use \"\" for the channel of the producer demo.\n");
+        return -1;
+    }
+
+    // Administrative operations on the channels in the working directory.
+    int AppMain(int argc, const char** argv)
+    {
+        if (argc < 2)
+            return usage(argv[0]);
+
+        const char* cmd = argv[1];
+        if (strcmp(cmd, "list") == 0)
+            return cmd_list((argc >= 3) ? argv[2] : ".");
+        if (argc < 3)
+            return usage(argv[0]);
+
+        const char* prefix = argv[2];
+        if (strcmp(cmd, "create") == 0)
+        {
+            const u32 index_kb      = (argc >= 4) ? (u32)atoi(argv[3]) : 1024;
+            const u32 data_kb       = (argc >= 5) ? (u32)atoi(argv[4]) : 10 * 1024;
+            const u32 max_consumers = (argc >= 6) ? (u32)atoi(argv[5]) : 16;
+            if (index_kb == 0 || data_kb == 0 || max_consumers == 0)
+                return usage(argv[0]);
+            return cmd_create(prefix, index_kb, data_kb, max_consumers);
+        }
+        if (strcmp(cmd, "destroy") == 0)
+            return cmd_destroy(prefix);
+        if (strcmp(cmd, "resize") == 0 && argc >= 5)
+        {
+            const u32 index_kb = (u32)atoi(argv[3]);
+            const u32 data_kb  = (u32)atoi(argv[4]);
+            if (index_kb == 0 || data_kb == 0)
+                return usage(argv[0]);
+            return cmd_resize(prefix, index_kb, data_kb);
+        }
+        if (strcmp(cmd, "release") == 0 && argc >= 4)
+            return cmd_release(prefix, (i32)atoi(argv[3]));
+        return usage(argv[0]);
+    }
+
+}  // namespace ncore
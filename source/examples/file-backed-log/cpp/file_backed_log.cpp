@@ -0,0 +1,65 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_mmio.h"
+
+#include <string.h>
+#include <stdio.h>
+#include <cstdlib>
+
+class malloc_based_alloc_t : public ncore::alloc_t
+{
+public:
+    void* v_allocate(ncore::u32 size, ncore::u32 alignment) { return malloc(size); }
+    void  v_deallocate(void* ptr) { free(ptr); }
+};
+
+namespace ncore
+{
+    static malloc_based_alloc_t s_malloc_based_alloc;
+
+    // A persistent append-only log on top of nmmio: the first 8 bytes of the file hold the
+    // number of bytes written so far, followed by the log text. Every run appends one line
+    // and prints the complete log.
+    int AppMain(int argc, const char** argv)
+    {
+        const char* path = (argc >= 2) ? argv[1] : "example.log.mm";
+        const u64   page = 4 * cKB;
+
+        nmmio::mappedfile_t* mf = nullptr;
+        nmmio::allocate(&s_malloc_based_alloc, mf);
+
+        bool ok = nmmio::exists(mf, path) ? nmmio::open_rw(mf, path) : nmmio::create_rw(mf, path, page);
+        if (ok)
+        {
+            char line[128];
+            int  n = snprintf(line, sizeof(line), "log entry written by '%s'\n", argv[0]);
+            if (n < 0)
+                n = 0;
+            if (n >= (int)sizeof(line))
+                n = (int)sizeof(line) - 1;
+
+            u64 used = *(const u64*)nmmio::address_ro(mf);
+            if (sizeof(u64) + used + n > nmmio::size(mf))
+                ok = nmmio::extend_size(mf, nmmio::size(mf) + page);
+
+            if (ok)
+            {
+                u8* base = (u8*)nmmio::address_rw(mf);
+                memcpy(base + sizeof(u64) + used, line, n);
+                used += n;
+                *(u64*)base = used;
+                nmmio::sync(mf);
+
+                printf("%.*s", (int)used, (const char*)base + sizeof(u64));
+            }
+            nmmio::close(mf);
+        }
+
+        if (!ok)
+            printf("file-backed-log: failed to open or extend '%s'\n", path);
+
+        nmmio::deallocate(&s_malloc_based_alloc, mf);
+        return ok ? 0 : -1;
+    }
+
+}  // namespace ncore
@@ -0,0 +1,46 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_mmmq.h"
+
+#include <stdio.h>
+#include <cstdlib>
+
+class malloc_based_alloc_t : public ncore::alloc_t
+{
+public:
+    void* v_allocate(ncore::u32 size, ncore::u32 alignment) { return malloc(size); }
+    void  v_deallocate(void* ptr) { free(ptr); }
+};
+
+namespace ncore
+{
+    static malloc_based_alloc_t s_malloc_based_alloc;
+
+    // Smallest possible consumer: attach to the channel written by 'hello-producer' and print
+    // every message that is currently available.
+    int AppMain(int argc, const char** argv)
+    {
+        nmmmq::handle_t* h = nmmmq::create_handle(&s_malloc_based_alloc);
+
+        i32 slot   = -1;
+        i32 result = nmmmq::attach_consumer(h, "hello_index.mm", "hello_data.mm", "hello_control.mm");
+        if (result == 0)
+            result = nmmmq::register_consumer(h, "hello", 0, slot);
+
+        if (result == 0)
+        {
+            const u8* msg_data;
+            u32       msg_len;
+            while (nmmmq::consumer_drain(h, slot, msg_data, msg_len))
+                printf("hello-consumer: %.*s\n", msg_len, msg_data);
+        }
+        else
+        {
+            printf("hello-consumer: %s\n", nmmmq::error_str(result));
+        }
+
+        nmmmq::destroy_handle(h);
+        return result == 0 ? 0 : -1;
+    }
+
+}  // namespace ncore
@@ -0,0 +1,41 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_mmmq.h"
+
+#include <string.h>
+#include <stdio.h>
+#include <cstdlib>
+
+class malloc_based_alloc_t : public ncore::alloc_t
+{
+public:
+    void* v_allocate(ncore::u32 size, ncore::u32 alignment) { return malloc(size); }
+    void  v_deallocate(void* ptr) { free(ptr); }
+};
+
+namespace ncore
+{
+    static malloc_based_alloc_t s_malloc_based_alloc;
+
+    // Smallest possible producer: create the channel, publish a single message and exit.
+    // Run 'hello-consumer' afterwards in the same directory to read it back.
+    int AppMain(int argc, const char** argv)
+    {
+        nmmmq::handle_t* h = nmmmq::create_handle(&s_malloc_based_alloc);
+
+        nmmmq::config_t config(64 * cKB, 1 * cMB, 4);
+
+        i32 result = nmmmq::init_producer(h, config, "hello_index.mm", "hello_data.mm", "hello_control.mm", "mmq_hello_new_sem", "mmq_hello_reg_sem");
+        if (result == 0)
+        {
+            const char* msg = "hello, world";
+            result          = nmmmq::publish(h, msg, (u32)strlen(msg) + 1);
+        }
+
+        printf("hello-producer: %s\n", nmmmq::error_str(result));
+
+        nmmmq::destroy_handle(h);
+        return result == 0 ? 0 : -1;
+    }
+
+}  // namespace ncore
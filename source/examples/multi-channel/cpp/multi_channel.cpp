@@ -0,0 +1,99 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_mmmq.h"
+
+#include <stdio.h>
+#include <cstdlib>
+
+class malloc_based_alloc_t : public ncore::alloc_t
+{
+public:
+    void* v_allocate(ncore::u32 size, ncore::u32 alignment) { return malloc(size); }
+    void  v_deallocate(void* ptr) { free(ptr); }
+};
+
+namespace ncore
+{
+    static malloc_based_alloc_t s_malloc_based_alloc;
+
+    struct channel_t
+    {
+        const char* m_name;
+        const char* m_index_path;
+        const char* m_data_path;
+        const char* m_control_path;
+        const char* m_new_sem_name;
+        const char* m_reg_sem_name;
+    };
+
+    static const channel_t s_channels[] = {
+        {"prices", "prices_index.mm", "prices_data.mm", "prices_control.mm", "mmq_prices_new_sem", "mmq_prices_reg_sem"},
+        {"orders", "orders_index.mm", "orders_data.mm", "orders_control.mm", "mmq_orders_new_sem", "mmq_orders_reg_sem"},
+    };
+    static const i32 s_num_channels = sizeof(s_channels) / sizeof(s_channels[0]);
+
+    // One process owning the producer side of two independent channels and a consumer that
+    // drains both of them in a round-robin fashion.
+    int AppMain(int argc, const char** argv)
+    {
+        nmmmq::handle_t* producers[s_num_channels];
+        nmmmq::handle_t* consumers[s_num_channels];
+        i32              slots[s_num_channels];
+
+        nmmmq::config_t config(64 * cKB, 1 * cMB, 4);
+
+        i32 result = 0;
+        for (i32 i = 0; i < s_num_channels; ++i)
+        {
+            const channel_t& c = s_channels[i];
+            producers[i]       = nmmmq::create_handle(&s_malloc_based_alloc);
+            consumers[i]       = nmmmq::create_handle(&s_malloc_based_alloc);
+            if (result == 0)
+                result = nmmmq::init_producer(producers[i], config, c.m_index_path, c.m_data_path, c.m_control_path, c.m_new_sem_name, c.m_reg_sem_name);
+            if (result == 0)
+                result = nmmmq::attach_consumer(consumers[i], c.m_index_path, c.m_data_path, c.m_control_path);
+            if (result == 0)
+                result = nmmmq::register_consumer(consumers[i], "multi-channel", 0, slots[i]);
+        }
+
+        if (result == 0)
+        {
+            char msg[64];
+            for (i32 n = 0; n < 4 && result == 0; ++n)
+            {
+                for (i32 i = 0; i < s_num_channels && result == 0; ++i)
+                {
+                    const int len = snprintf(msg, sizeof(msg), "%s #%d", s_channels[i].m_name, n);
+                    result        = nmmmq::publish(producers[i], msg, (u32)len + 1);
+                }
+            }
+
+            bool drained = false;
+            while (!drained && result == 0)
+            {
+                drained = true;
+                for (i32 i = 0; i < s_num_channels; ++i)
+                {
+                    const u8* msg_data;
+                    u32       msg_len;
+                    if (nmmmq::consumer_drain(consumers[i], slots[i], msg_data, msg_len))
+                    {
+                        printf("[%s] %.*s\n", s_channels[i].m_name, msg_len, msg_data);
+                        drained = false;
+                    }
+                }
+            }
+        }
+
+        if (result != 0)
+            printf("multi-channel: %s\n", nmmmq::error_str(result));
+
+        for (i32 i = 0; i < s_num_channels; ++i)
+        {
+            nmmmq::destroy_handle(consumers[i]);
+            nmmmq::destroy_handle(producers[i]);
+        }
+        return result == 0 ? 0 : -1;
+    }
+
+}  // namespace ncore
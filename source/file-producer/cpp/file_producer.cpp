@@ -0,0 +1,125 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_mmmq.h"
+
+#include <string.h>
+#include <stdio.h>
+#include <cstdlib>
+
+class malloc_based_alloc_t : public ncore::alloc_t
+{
+public:
+    void* v_allocate(ncore::u32 size, ncore::u32 alignment) { return malloc(size); }
+    void  v_deallocate(void* ptr) { free(ptr); }
+};
+
+namespace ncore
+{
+    static malloc_based_alloc_t s_malloc_based_alloc;
+
+    static int usage(const char* app)
+    {
+        printf("Usage: %s [--lines] [--chunk <bytes>] <file> [prefix]\n", app);
+        printf("Publishes <file> to the channel <prefix>index.mm, <prefix>data.mm, <prefix>control.mm,\n");
+        printf("one message per line with --lines, otherwise in chunks of --chunk bytes (default 4096).\n");
+        return -1;
+    }
+
+    // Streams a file into a channel, '-' reads from stdin.
+    int AppMain(int argc, const char** argv)
+    {
+        bool        lines  = false;
+        u32         chunk  = 4096;
+        const char* path   = nullptr;
+        const char* prefix = "";
+        for (int i = 1; i < argc; ++i)
+        {
+            if (strcmp(argv[i], "--lines") == 0)
+                lines = true;
+            else if (strcmp(argv[i], "--chunk") == 0 && i + 1 < argc)
+                chunk = (u32)atoi(argv[++i]);
+            else if (path == nullptr && (argv[i][0] != '-' || argv[i][1] == 0))
+                path = argv[i];
+            else if (argv[i][0] != '-')
+                prefix = argv[i];
+            else
+                return usage(argv[0]);
+        }
+        if (path == nullptr || chunk == 0)
+            return usage(argv[0]);
+
+        FILE* in = (strcmp(path, "-") == 0) ? stdin : fopen(path, "rb");
+        if (in == nullptr)
+        {
+            printf("cmmio-file-producer: cannot open '%s'\n", path);
+            return -1;
+        }
+
+        char index_path[256], data_path[256], control_path[256];
+        char new_sem_name[52], reg_sem_name[52];
+        snprintf(index_path, sizeof(index_path), "%sindex.mm", prefix);
+        snprintf(data_path, sizeof(data_path), "%sdata.mm", prefix);
+        snprintf(control_path, sizeof(control_path), "%scontrol.mm", prefix);
+        snprintf(new_sem_name, sizeof(new_sem_name), "%smmq_new_entries_sem", prefix);
+        snprintf(reg_sem_name, sizeof(reg_sem_name), "%smmq_registry_lock_sem", prefix);
+
+        nmmmq::handle_t* h = nmmmq::create_handle(&s_malloc_based_alloc);
+        nmmmq::config_t  config(1 * cMB, 10 * cMB, 16);
+        i32              result = nmmmq::init_producer(h, config, index_path, data_path, control_path, new_sem_name, reg_sem_name);
+        if (result != 0)
+        {
+            printf("cmmio-file-producer: init_producer failed (err = %s)\n", nmmmq::error_str(result));
+            nmmmq::destroy_handle(h);
+            if (in != stdin)
+                fclose(in);
+            return -1;
+        }
+
+        u64 count = 0;
+        u64 bytes = 0;
+        if (lines)
+        {
+            char*  line     = nullptr;
+            size_t line_cap = 0;
+            for (ssize_t len; result == 0 && (len = getline(&line, &line_cap, in)) > 0;)
+            {
+                if (line[len - 1] == '\n')
+                    len--;
+                result = nmmmq::publish(h, line, (u32)len);
+                if (result == 0)
+                {
+                    count++;
+                    bytes += (u64)len;
+                }
+            }
+            free(line);
+        }
+        else
+        {
+            char* buffer = (char*)malloc(chunk);
+            for (size_t len; result == 0 && (len = fread(buffer, 1, chunk, in)) > 0;)
+            {
+                result = nmmmq::publish(h, buffer, (u32)len);
+                if (result == 0)
+                {
+                    count++;
+                    bytes += (u64)len;
+                }
+            }
+            free(buffer);
+        }
+
+        if (in != stdin)
+            fclose(in);
+        nmmmq::destroy_handle(h);
+
+        if (result != 0)
+        {
+            printf("cmmio-file-producer: publish failed after %llu messages (err = %s)\n", (unsigned long long)count, nmmmq::error_str(result));
+            return -1;
+        }
+        printf("cmmio-file-producer: published %llu messages, %llu bytes\n", (unsigned long long)count, (unsigned long long)bytes);
+        return 0;
+    }
+
+}  // namespace ncore
@@ -0,0 +1,131 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_mmmq.h"
+
+#include <unistd.h>
+#include <string.h>
+#include <stdio.h>
+#include <cstdlib>
+
+class malloc_based_alloc_t : public ncore::alloc_t
+{
+public:
+    void* v_allocate(ncore::u32 size, ncore::u32 alignment) { return malloc(size); }
+    void  v_deallocate(void* ptr) { free(ptr); }
+};
+
+namespace ncore
+{
+    static malloc_based_alloc_t s_malloc_based_alloc;
+
+    // exit codes, suitable for a liveness probe
+    enum
+    {
+        HEALTH_OK          = 0,  // channel valid, all consumers within the lag limit
+        HEALTH_MISSING     = 1,  // one of the channel files does not exist
+        HEALTH_CORRUPT     = 2,  // the files exist but the headers are invalid or of another ABI version
+        HEALTH_CONSUMER    = 3,  // at least one consumer lags or has been idle for more than the limit
+        HEALTH_NO_CONSUMER = 4,  // --min-consumers is not met
+        HEALTH_USAGE       = 64,
+    };
+
+    static int usage(const char* app)
+    {
+        printf("Usage: %s [--quiet] [--max-lag <messages>] [--max-idle <ms>] [--min-consumers <count>] [prefix]\n", app);
+        printf("Checks the channel <prefix>index.mm, <prefix>data.mm, <prefix>control.mm in the working directory.\n");
+        printf("Exit code 0 = healthy, 1 = missing, 2 = corrupt, 3 = consumer lagging or idle, 4 = too few consumers.\n");
+        printf("--max-idle is for consumers that call consumer_heartbeat, the idle time counts from the last heartbeat.\n");
+        return HEALTH_USAGE;
+    }
+
+    // Verifies the headers of a channel and the progress of its consumers, read-only.
+    int AppMain(int argc, const char** argv)
+    {
+        bool        quiet         = false;
+        u64         max_lag       = 0;  // 0 = no limit
+        u64         max_idle_ms   = 0;  // 0 = no limit
+        u32         min_consumers = 0;
+        const char* prefix        = "";
+        for (int i = 1; i < argc; ++i)
+        {
+            if (strcmp(argv[i], "--quiet") == 0)
+                quiet = true;
+            else if (strcmp(argv[i], "--max-lag") == 0 && i + 1 < argc)
+                max_lag = (u64)strtoull(argv[++i], nullptr, 10);
+            else if (strcmp(argv[i], "--max-idle") == 0 && i + 1 < argc)
+                max_idle_ms = (u64)strtoull(argv[++i], nullptr, 10);
+            else if (strcmp(argv[i], "--min-consumers") == 0 && i + 1 < argc)
+                min_consumers = (u32)atoi(argv[++i]);
+            else if (argv[i][0] != '-')
+                prefix = argv[i];
+            else
+                return usage(argv[0]);
+        }
+
+        char index_path[256], data_path[256], control_path[256];
+        snprintf(index_path, sizeof(index_path), "%sindex.mm", prefix);
+        snprintf(data_path, sizeof(data_path), "%sdata.mm", prefix);
+        snprintf(control_path, sizeof(control_path), "%scontrol.mm", prefix);
+
+        if (access(index_path, R_OK) != 0 || access(data_path, R_OK) != 0 || access(control_path, R_OK) != 0)
+        {
+            if (!quiet)
+                printf("cmmio-health: channel '%s' is missing\n", prefix);
+            return HEALTH_MISSING;
+        }
+
+        nmmmq::handle_t* h = nmmmq::create_handle(&s_malloc_based_alloc);
+
+        nmmmq::info_t info;
+        i32           result = nmmmq::attach_inspector(h, index_path, data_path, control_path);
+        if (result == 0)
+            result = nmmmq::inspect(h, info);
+        if (result != 0)
+        {
+            if (!quiet)
+                printf("cmmio-health: channel '%s' is corrupt (%s)\n", prefix, nmmmq::error_str(result));
+            nmmmq::destroy_handle(h);
+            return HEALTH_CORRUPT;
+        }
+
+        int status = HEALTH_OK;
+        u32 active = 0;
+        for (i32 i = 0; i < (i32)info.max_consumers; ++i)
+        {
+            nmmmq::consumer_info_t ci;
+            if (nmmmq::inspect_consumer(h, i, ci) != 0 || !ci.active)
+                continue;
+
+            active++;
+            const u64 lag = (info.next_seq > ci.last_seq) ? (info.next_seq - ci.last_seq) : 0;
+            if (max_lag > 0 && lag > max_lag)
+            {
+                if (!quiet)
+                    printf("cmmio-health: consumer '%s' lags %llu messages (limit %llu)\n", ci.name, (unsigned long long)lag, (unsigned long long)max_lag);
+                status = HEALTH_CONSUMER;
+            }
+
+            const u64 now     = nmmmq::clock_ns();
+            const u64 idle_ms = (now > ci.last_update_ns) ? (now - ci.last_update_ns) / 1000000 : 0;
+            if (max_idle_ms > 0 && idle_ms > max_idle_ms)
+            {
+                if (!quiet)
+                    printf("cmmio-health: consumer '%s' has been idle for %llu ms (limit %llu)\n", ci.name, (unsigned long long)idle_ms, (unsigned long long)max_idle_ms);
+                status = HEALTH_CONSUMER;
+            }
+        }
+        nmmmq::destroy_handle(h);
+
+        if (status == HEALTH_OK && active < min_consumers)
+        {
+            if (!quiet)
+                printf("cmmio-health: %u active consumers, expected at least %u\n", active, min_consumers);
+            status = HEALTH_NO_CONSUMER;
+        }
+
+        if (status == HEALTH_OK && !quiet)
+            printf("cmmio-health: channel '%s' ok, next_seq %llu, %u active consumers\n", prefix, (unsigned long long)info.next_seq, active);
+        return status;
+    }
+
+}  // namespace ncore
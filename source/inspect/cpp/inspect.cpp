@@ -0,0 +1,73 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_mmmq.h"
+
+#include <stdio.h>
+#include <cstdlib>
+
+class malloc_based_alloc_t : public ncore::alloc_t
+{
+public:
+    void* v_allocate(ncore::u32 size, ncore::u32 alignment) { return malloc(size); }
+    void  v_deallocate(void* ptr) { free(ptr); }
+};
+
+namespace ncore
+{
+    static malloc_based_alloc_t s_malloc_based_alloc;
+
+    static void print_info(const nmmmq::info_t& info)
+    {
+        printf("index.mm    version %u, next_seq %llu, capacity %llu entries\n", info.index_version, (unsigned long long)info.next_seq, (unsigned long long)info.index_capacity);
+        printf("data.mm     version %u, write_pos %llu of %llu bytes (%.1f%%)\n", info.data_version, (unsigned long long)info.data_write_pos, (unsigned long long)info.data_capacity,
+               info.data_capacity > 0 ? (100.0 * (double)info.data_write_pos / (double)info.data_capacity) : 0.0);
+        printf("control.mm  version %u, notify_seq %llu, max_consumers %u\n", info.control_version, (unsigned long long)info.notify_seq, info.max_consumers);
+        printf("semaphores  new entries '%s', registry lock '%s'\n", info.new_entries_sem, info.registry_lock_sem);
+    }
+
+    static void print_consumers(nmmmq::handle_t* h, const nmmmq::info_t& info)
+    {
+        printf("consumers:\n");
+        for (i32 i = 0; i < (i32)info.max_consumers; ++i)
+        {
+            nmmmq::consumer_info_t ci;
+            if (nmmmq::inspect_consumer(h, i, ci) != 0 || !ci.active)
+                continue;
+
+            const u64 lag  = (info.next_seq > ci.last_seq) ? (info.next_seq - ci.last_seq) : 0;
+            const u64 now  = nmmmq::clock_ns();
+            const u64 idle = (now > ci.last_update_ns) ? (now - ci.last_update_ns) : 0;
+            printf("  [%2d] '%s' last_seq %llu, lag %llu, idle %llu ms\n", i, ci.name, (unsigned long long)ci.last_seq, (unsigned long long)lag, (unsigned long long)(idle / 1000000));
+        }
+    }
+
+    // Attaches read-only to an existing channel and dumps its header state and consumer slots.
+    int AppMain(int argc, const char** argv)
+    {
+        const char* index_path   = (argc >= 2) ? argv[1] : "index.mm";
+        const char* data_path    = (argc >= 3) ? argv[2] : "data.mm";
+        const char* control_path = (argc >= 4) ? argv[3] : "control.mm";
+
+        nmmmq::handle_t* h = nmmmq::create_handle(&s_malloc_based_alloc);
+
+        nmmmq::info_t info;
+        i32           result = nmmmq::attach_inspector(h, index_path, data_path, control_path);
+        if (result == 0)
+            result = nmmmq::inspect(h, info);
+
+        if (result == 0)
+        {
+            print_info(info);
+            print_consumers(h, info);
+        }
+        else
+        {
+            printf("mmio-inspect: %s\n", nmmmq::error_str(result));
+            printf("Usage: %s [index_path] [data_path] [control_path]\n", argv[0]);
+        }
+
+        nmmmq::destroy_handle(h);
+        return result == 0 ? 0 : -1;
+    }
+
+}  // namespace ncore
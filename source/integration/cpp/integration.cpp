@@ -0,0 +1,287 @@
+#include "ccore/c_target.h"
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_mmmq.h"
+
+#include <unistd.h>
+#include <signal.h>
+#include <string.h>
+#include <stdio.h>
+#include <cstdlib>
+#include <time.h>
+#include <sys/types.h>
+#include <sys/stat.h>
+#include <semaphore.h>
+#include <sys/wait.h>
+
+class malloc_based_alloc_t : public ncore::alloc_t
+{
+public:
+    void* v_allocate(ncore::u32 size, ncore::u32 alignment) { return malloc(size); }
+    void  v_deallocate(void* ptr) { free(ptr); }
+};
+
+namespace ncore
+{
+    static malloc_based_alloc_t s_malloc_based_alloc;
+    static alloc_t*             s_allocator = &s_malloc_based_alloc;
+
+    static char s_bin_dir[512];
+    static char s_prefix[128];
+
+    // Directory of this executable, the producer and consumer binaries are expected next to it.
+    // They are built with the same Options.AppPrefix, which is whatever comes before
+    // "cmmio-integration" in the name of this executable.
+    static void init_bin_dir(const char* argv0)
+    {
+        strncpy(s_bin_dir, argv0, sizeof(s_bin_dir) - 1);
+        char*       slash = strrchr(s_bin_dir, '/');
+        const char* name  = (slash != nullptr) ? slash + 1 : s_bin_dir;
+
+        const char*  app      = "cmmio-integration";
+        const size_t name_len = strlen(name);
+        const size_t app_len  = strlen(app);
+        if (name_len > app_len && strcmp(name + name_len - app_len, app) == 0 && name_len - app_len < sizeof(s_prefix))
+        {
+            memcpy(s_prefix, name, name_len - app_len);
+            s_prefix[name_len - app_len] = 0;
+        }
+
+        if (slash != nullptr)
+            *slash = 0;
+        else
+            strcpy(s_bin_dir, ".");
+    }
+
+    static pid_t spawn(const char* app, const char* arg1, const char* arg2, const char* arg3)
+    {
+        char path[640];
+        snprintf(path, sizeof(path), "%s/%s%s", s_bin_dir, s_prefix, app);
+
+        pid_t pid = fork();
+        if (pid == 0)
+        {
+            execl(path, app, arg1, arg2, arg3, (char*)nullptr);
+            printf("integration: failed to execute '%s'\n", path);
+            _exit(127);
+        }
+        return pid;
+    }
+
+    static void sleep_ms(u32 ms)
+    {
+        struct timespec ts;
+        ts.tv_sec  = ms / 1000;
+        ts.tv_nsec = (long)(ms % 1000) * 1000000;
+        nanosleep(&ts, NULL);
+    }
+
+    // Waits until the producer has published its first message, the headers are complete by then
+    // and consumers can attach. Returns false when that does not happen within @timeout_ms.
+    static bool wait_first_message(u32 timeout_ms)
+    {
+        for (u32 waited = 0; waited < timeout_ms; waited += 10)
+        {
+            nmmmq::handle_t* h = nmmmq::create_handle(s_allocator);
+            nmmmq::info_t    info;
+            const bool       published = nmmmq::attach_inspector(h, "index.mm", "data.mm", "control.mm") == 0 && nmmmq::inspect(h, info) == 0 && info.next_seq > 0;
+            nmmmq::destroy_handle(h);
+            if (published)
+                return true;
+            sleep_ms(10);
+        }
+        return false;
+    }
+
+    static bool is_running(pid_t pid)
+    {
+        int status = 0;
+        return waitpid(pid, &status, WNOHANG) == 0;
+    }
+
+    // Waits for @pid to exit within @timeout_ms, kills it otherwise. Returns true when the
+    // process exited normally with exit code 0.
+    static bool wait_exit(pid_t pid, u32 timeout_ms)
+    {
+        int status = 0;
+        for (u32 waited = 0; waited < timeout_ms; waited += 10)
+        {
+            const pid_t r = waitpid(pid, &status, WNOHANG);
+            if (r == pid)
+                return WIFEXITED(status) && WEXITSTATUS(status) == 0;
+            if (r < 0)
+                return false;
+            sleep_ms(10);
+        }
+        kill(pid, SIGKILL);
+        waitpid(pid, &status, 0);
+        return false;
+    }
+
+    static bool check(bool condition, const char* what)
+    {
+        printf("integration: %-60s %s\n", what, condition ? "ok" : "FAILED");
+        return condition;
+    }
+
+    // uid and gid the permission case drops to, 'nobody' on Linux and macOS
+    static const uid_t s_other_uid = 65534;
+    static const gid_t s_other_gid = 65534;
+
+    enum
+    {
+        ATTACH_OK      = 0,
+        ATTACH_REFUSED = 1,
+        ATTACH_NO_UID  = 2,
+        ATTACH_CRASHED = 3,
+    };
+
+    // Attaches a consumer to the channel @name in a child process that runs as the other uid,
+    // returns one of the ATTACH_ codes.
+    static int attach_as_other_uid(const char* name)
+    {
+        char index_path[64], data_path[64], control_path[64];
+        snprintf(index_path, sizeof(index_path), "%s_index.mm", name);
+        snprintf(data_path, sizeof(data_path), "%s_data.mm", name);
+        snprintf(control_path, sizeof(control_path), "%s_control.mm", name);
+
+        pid_t pid = fork();
+        if (pid == 0)
+        {
+            if (setgid(s_other_gid) != 0 || setuid(s_other_uid) != 0)
+                _exit(ATTACH_NO_UID);
+            nmmmq::handle_t* h      = nmmmq::create_handle(s_allocator);
+            const i32        result = nmmmq::attach_consumer(h, index_path, data_path, control_path);
+            nmmmq::destroy_handle(h);
+            _exit(result == 0 ? ATTACH_OK : ATTACH_REFUSED);
+        }
+
+        int status = 0;
+        if (pid < 0 || waitpid(pid, &status, 0) != pid || !WIFEXITED(status))
+            return ATTACH_CRASHED;
+        return WEXITSTATUS(status);
+    }
+
+    // Creates the channel @name with @mode, the umask is cleared so that @mode is what ends up on
+    // the files and semaphores.
+    static nmmmq::handle_t* create_channel(const char* name, u16 mode)
+    {
+        char index_path[64], data_path[64], control_path[64], new_sem[64], reg_sem[64];
+        snprintf(index_path, sizeof(index_path), "%s_index.mm", name);
+        snprintf(data_path, sizeof(data_path), "%s_data.mm", name);
+        snprintf(control_path, sizeof(control_path), "%s_control.mm", name);
+        snprintf(new_sem, sizeof(new_sem), "%s_new_sem", name);
+        snprintf(reg_sem, sizeof(reg_sem), "%s_reg_sem", name);
+
+        nmmmq::config_t config(64 * cKB, 64 * cKB, 4);
+        config.mode = mode;
+
+        const mode_t     umask_was = umask(0);
+        nmmmq::handle_t* h         = nmmmq::create_handle(s_allocator);
+        if (nmmmq::init_producer(h, config, index_path, data_path, control_path, new_sem, reg_sem) != 0)
+        {
+            nmmmq::destroy_handle(h);
+            h = nullptr;
+        }
+        umask(umask_was);
+        return h;
+    }
+
+    static void remove_channel(const char* name, nmmmq::handle_t* h)
+    {
+        if (h != nullptr)
+            nmmmq::destroy_handle(h);
+
+        char path[64];
+        snprintf(path, sizeof(path), "%s_index.mm", name);
+        unlink(path);
+        snprintf(path, sizeof(path), "%s_data.mm", name);
+        unlink(path);
+        snprintf(path, sizeof(path), "%s_control.mm", name);
+        unlink(path);
+        snprintf(path, sizeof(path), "%s_new_sem", name);
+        sem_unlink(path);
+        snprintf(path, sizeof(path), "%s_reg_sem", name);
+        sem_unlink(path);
+    }
+
+    // A channel created with mode 0600 refuses a consumer running as another uid, and that
+    // consumer fails cleanly instead of crashing. A 0666 channel is attached first by the same
+    // uid, so a refusal can only come from the mode and not from the working directory being
+    // out of reach. Returns true when the case passed or was skipped.
+    static bool check_permissions()
+    {
+        if (geteuid() != 0)
+        {
+            printf("integration: %-60s %s\n", "consumer of another uid is refused by a 0600 channel", "skipped (needs root to switch to a second uid)");
+            return true;
+        }
+
+        remove_channel("integration_open", nullptr);
+        remove_channel("integration_private", nullptr);
+
+        bool             ok      = true;
+        nmmmq::handle_t* open_ch = create_channel("integration_open", 0666);
+        ok                       = check(open_ch != nullptr, "producer created a 0666 channel") && ok;
+
+        const int open_attach = (open_ch != nullptr) ? attach_as_other_uid("integration_open") : ATTACH_CRASHED;
+        if (ok && open_attach != ATTACH_OK)
+        {
+            const char* reason = (open_attach == ATTACH_NO_UID) ? "skipped (cannot switch to uid 65534)" : "skipped (uid 65534 cannot attach to a 0666 channel here)";
+            printf("integration: %-60s %s\n", "consumer of another uid is refused by a 0600 channel", reason);
+            remove_channel("integration_open", open_ch);
+            return true;
+        }
+        remove_channel("integration_open", open_ch);
+
+        nmmmq::handle_t* private_ch = create_channel("integration_private", 0600);
+        ok                          = check(private_ch != nullptr, "producer created a 0600 channel") && ok;
+        if (private_ch != nullptr)
+            ok = check(attach_as_other_uid("integration_private") == ATTACH_REFUSED, "consumer of another uid is refused by a 0600 channel") && ok;
+        remove_channel("integration_private", private_ch);
+        return ok;
+    }
+
+    // Runs the real producer and consumer binaries as child processes:
+    // - consumers attach while the producer is publishing (cross-process visibility)
+    // - the producer exits before the consumers are done (consumers keep draining after detach)
+    // - a late consumer attaches after the producer is gone and still sees the full history
+    // - a consumer running as another uid is refused by a channel created with mode 0600
+    int AppMain(int argc, const char** argv)
+    {
+        init_bin_dir(argv[0]);
+
+        unlink("index.mm");
+        unlink("data.mm");
+        unlink("control.mm");
+
+        const char* num_messages = "100";
+
+        bool  ok       = true;
+        pid_t producer = spawn("producer", num_messages, "5", nullptr);
+
+        ok = check(wait_first_message(5000), "producer created the channel and published") && ok;
+
+        // the consumers verify the content of every message, see consumer.cpp
+        pid_t consumer1 = spawn("consumer", "integration1", "0", num_messages);
+        pid_t consumer2 = spawn("consumer", "integration2", "0", num_messages);
+
+        ok = check(wait_exit(producer, 10000), "producer published all messages and exited") && ok;
+
+        // a consumer takes 80 ms per message, far longer than the producer, so both of them
+        // are still draining after the producer detached
+        const bool draining = is_running(consumer1) && is_running(consumer2);
+        ok                  = check(draining, "consumers were still draining when the producer exited") && ok;
+
+        pid_t consumer3 = spawn("consumer", "integration3", "0", num_messages);
+
+        ok = check(wait_exit(consumer1, 30000), "consumer 1 received all messages") && ok;
+        ok = check(wait_exit(consumer2, 30000), "consumer 2 received all messages") && ok;
+        ok = check(wait_exit(consumer3, 30000), "late consumer received all messages") && ok;
+
+        ok = check_permissions() && ok;
+
+        return ok ? 0 : -1;
+    }
+
+}  // namespace ncore
@@ -0,0 +1,301 @@
+#include "ccore/c_target.h"
+
+#include <unistd.h>
+#include <string.h>
+#include <stdio.h>
+#include <cstdlib>
+#include <time.h>
+#include <sched.h>
+#include <signal.h>
+#include <netinet/in.h>
+#include <netinet/tcp.h>
+#include <sys/mman.h>
+#include <sys/socket.h>
+#include <sys/wait.h>
+
+namespace ncore
+{
+    static u64 now_ns()
+    {
+        struct timespec ts;
+        clock_gettime(CLOCK_MONOTONIC, &ts);
+        return (u64)ts.tv_sec * 1000000000ull + (u64)ts.tv_nsec;
+    }
+
+    // Results written with --json, in the format of cmmio-bench so that cmmio-bench-compare can
+    // compare runs of the same transport.
+    struct result_t
+    {
+        char   name[32];
+        char   metric[32];
+        double value;
+        bool   higher_is_better;
+    };
+
+    static result_t s_results[32];
+    static u32      s_num_results = 0;
+
+    static void add_result(const char* scenario, u32 msg_size, const char* metric, double value, bool higher_is_better)
+    {
+        if (s_num_results >= sizeof(s_results) / sizeof(s_results[0]))
+            return;
+        result_t& r = s_results[s_num_results++];
+        snprintf(r.name, sizeof(r.name), "%s/%u", scenario, msg_size);
+        snprintf(r.metric, sizeof(r.metric), "%s", metric);
+        r.value            = value;
+        r.higher_is_better = higher_is_better;
+    }
+
+    static bool write_results(const char* path, u32 msg_count)
+    {
+        FILE* out = fopen(path, "w");
+        if (out == nullptr)
+        {
+            printf("ipc-bench: cannot create '%s'\n", path);
+            return false;
+        }
+        fprintf(out, "{\"message_count\": %u, \"results\": [\n", msg_count);
+        for (u32 i = 0; i < s_num_results; ++i)
+        {
+            const result_t& r = s_results[i];
+            fprintf(out, "{\"name\": \"%s\", \"metric\": \"%s\", \"value\": %.1f, \"better\": \"%s\"}%s\n", r.name, r.metric, r.value, r.higher_is_better ? "higher" : "lower", (i + 1 < s_num_results) ? "," : "");
+        }
+        fprintf(out, "]}\n");
+        fclose(out);
+        return true;
+    }
+
+    static int cmp_u64(const void* a, const void* b)
+    {
+        const u64 x = *(const u64*)a;
+        const u64 y = *(const u64*)b;
+        return (x < y) ? -1 : ((x > y) ? 1 : 0);
+    }
+
+    enum transport_t
+    {
+        TRANSPORT_PIPE,  // pipe()
+        TRANSPORT_UNIX,  // socketpair(AF_UNIX, SOCK_STREAM)
+        TRANSPORT_TCP,   // a TCP connection over the loopback interface, with TCP_NODELAY
+    };
+
+    static const char* transport_name(transport_t transport)
+    {
+        switch (transport)
+        {
+            case TRANSPORT_PIPE: return "pipe";
+            case TRANSPORT_UNIX: return "unix";
+            case TRANSPORT_TCP: return "tcp";
+        }
+        return "ipc";
+    }
+
+    // Opens a one way byte stream, fds[0] is read by the consumer and fds[1] written by the producer.
+    static bool open_transport(transport_t transport, int fds[2])
+    {
+        if (transport == TRANSPORT_PIPE)
+            return pipe(fds) == 0;
+        if (transport == TRANSPORT_UNIX)
+            return socketpair(AF_UNIX, SOCK_STREAM, 0, fds) == 0;
+
+        const int listener = socket(AF_INET, SOCK_STREAM, 0);
+        if (listener < 0)
+            return false;
+        struct sockaddr_in addr;
+        memset(&addr, 0, sizeof(addr));
+        addr.sin_family      = AF_INET;
+        addr.sin_addr.s_addr = htonl(INADDR_LOOPBACK);
+        addr.sin_port        = 0;  // any free port
+        socklen_t addr_len   = sizeof(addr);
+        bool      ok         = bind(listener, (struct sockaddr*)&addr, sizeof(addr)) == 0 && listen(listener, 1) == 0 && getsockname(listener, (struct sockaddr*)&addr, &addr_len) == 0;
+        fds[1]               = ok ? socket(AF_INET, SOCK_STREAM, 0) : -1;
+        ok                   = ok && fds[1] >= 0 && connect(fds[1], (struct sockaddr*)&addr, sizeof(addr)) == 0;
+        fds[0]               = ok ? accept(listener, nullptr, nullptr) : -1;
+        ok                   = ok && fds[0] >= 0;
+        close(listener);
+
+        const int one = 1;
+        ok            = ok && setsockopt(fds[1], IPPROTO_TCP, TCP_NODELAY, &one, sizeof(one)) == 0;
+        if (!ok && fds[1] >= 0)
+            close(fds[1]);
+        if (!ok && fds[0] >= 0)
+            close(fds[0]);
+        return ok;
+    }
+
+    // Streams have no message boundaries, every message is exactly msg_size bytes.
+    static bool read_full(int fd, u8* data, u32 len)
+    {
+        while (len > 0)
+        {
+            const ssize_t n = read(fd, data, len);
+            if (n <= 0)
+                return false;
+            data += n;
+            len -= (u32)n;
+        }
+        return true;
+    }
+
+    static bool write_full(int fd, const u8* data, u32 len)
+    {
+        while (len > 0)
+        {
+            const ssize_t n = write(fd, data, len);
+            if (n <= 0)
+                return false;
+            data += n;
+            len -= (u32)n;
+        }
+        return true;
+    }
+
+    // Same scenario as the wakeup-block scenario of cmmio-bench: a forked consumer blocks in read(),
+    // each message carries its send time and the producer only sends the next one after the
+    // consumer acknowledged the previous one. Then the same consumer reads @msg_count messages
+    // that are written back to back, for the throughput.
+    static bool bench_transport(transport_t transport, u32 msg_count, u32 msg_size)
+    {
+        int fds[2] = {-1, -1};
+        if (!open_transport(transport, fds))
+        {
+            printf("ipc-bench: cannot open a %s\n", transport_name(transport));
+            return false;
+        }
+
+        // shared[0] counts the acknowledged messages, shared[1] is the time the last message of the
+        // throughput run arrived, the latency samples follow
+        const size_t shared_size = sizeof(u64) * ((size_t)msg_count + 2);
+        u64*         shared      = (u64*)mmap(nullptr, shared_size, PROT_READ | PROT_WRITE, MAP_SHARED | MAP_ANON, -1, 0);
+        bool         ok          = shared != (u64*)MAP_FAILED;
+
+        const pid_t child = ok ? fork() : (pid_t)-1;
+        if (child == 0)
+        {
+            close(fds[1]);
+            volatile u64* acked   = shared;
+            u64*          samples = shared + 2;
+            u8*           msg     = (u8*)malloc(msg_size);
+            for (u32 i = 0; i < msg_count; ++i)
+            {
+                if (!read_full(fds[0], msg, msg_size))
+                    _exit(1);
+                samples[i] = now_ns() - *(const u64*)msg;
+                __atomic_store_n(acked, (u64)(i + 1), __ATOMIC_RELEASE);
+            }
+            for (u32 i = 0; i < msg_count; ++i)
+            {
+                if (!read_full(fds[0], msg, msg_size))
+                    _exit(1);
+            }
+            shared[1] = now_ns();
+            _exit(0);
+        }
+        close(fds[0]);
+        ok = ok && child > 0;
+
+        u64 throughput_begin = 0;
+        if (ok)
+        {
+            u8* msg = (u8*)malloc(msg_size);
+            memset(msg, 0xCD, msg_size);
+            int  status = 0;
+            bool exited = false;
+            for (u32 i = 0; i < msg_count && ok; ++i)
+            {
+                *(u64*)msg = now_ns();
+                ok         = write_full(fds[1], msg, msg_size);
+                // yield while waiting for the acknowledgement so that the consumer also gets the
+                // cpu on a single core machine, stop when the consumer exited without it
+                while (ok && !exited && __atomic_load_n(shared, __ATOMIC_ACQUIRE) <= i)
+                {
+                    sched_yield();
+                    exited = waitpid(child, &status, WNOHANG) == child;
+                    ok     = !exited || __atomic_load_n(shared, __ATOMIC_ACQUIRE) > i;
+                }
+            }
+
+            throughput_begin = now_ns();
+            for (u32 i = 0; i < msg_count && ok; ++i)
+                ok = write_full(fds[1], msg, msg_size);
+            free(msg);
+
+            if (!ok && !exited)
+                kill(child, SIGKILL);
+            if (!exited)
+                waitpid(child, &status, 0);
+            ok = ok && WIFEXITED(status) && WEXITSTATUS(status) == 0;
+        }
+        close(fds[1]);
+
+        if (!ok)
+        {
+            printf("ipc-bench: %s run failed\n", transport_name(transport));
+        }
+        else
+        {
+            u64* samples = shared + 2;
+            qsort(samples, msg_count, sizeof(u64), cmp_u64);
+            const double seconds = (double)(shared[1] - throughput_begin) / 1e9;
+            const double mb      = ((double)msg_count * (double)msg_size) / (1024.0 * 1024.0);
+            printf("%-6s %6u bytes x %8u: min %6llu ns, p50 %6llu ns, p99 %6llu ns, p99.9 %6llu ns, max %8llu ns, %10.0f msg/s %9.1f MB/s\n", transport_name(transport), msg_size, msg_count, (unsigned long long)samples[0], (unsigned long long)samples[msg_count / 2],
+                   (unsigned long long)samples[(u64)msg_count * 99 / 100], (unsigned long long)samples[(u64)msg_count * 999 / 1000], (unsigned long long)samples[msg_count - 1], msg_count / seconds, mb / seconds);
+            add_result(transport_name(transport), msg_size, "p50_ns", (double)samples[msg_count / 2], false);
+            add_result(transport_name(transport), msg_size, "p99_ns", (double)samples[(u64)msg_count * 99 / 100], false);
+            add_result(transport_name(transport), msg_size, "msgs_per_s", msg_count / seconds, true);
+        }
+
+        if (shared != (u64*)MAP_FAILED)
+            munmap(shared, shared_size);
+        return ok;
+    }
+
+    // Moves the workload of the cmmio-bench wakeup scenarios over standard IPC, so that the numbers
+    // can be put next to those of the channel measured on the same machine.
+    int AppMain(int argc, const char** argv)
+    {
+        u32         msg_count = 10000;
+        u32         msg_size  = 64;
+        const char* json_path = nullptr;
+        const char* only      = nullptr;
+        for (int i = 1; i < argc; ++i)
+        {
+            if (strcmp(argv[i], "--json") == 0 && i + 1 < argc)
+                json_path = argv[++i];
+            else if (strcmp(argv[i], "--size") == 0 && i + 1 < argc)
+                msg_size = (u32)atoi(argv[++i]);
+            else if (strcmp(argv[i], "--transport") == 0 && i + 1 < argc)
+                only = argv[++i];
+            else
+                msg_count = (u32)atoi(argv[i]);
+        }
+        if (msg_count == 0 || msg_size < sizeof(u64))
+        {
+            printf("Usage: %s [--json <results.json>] [--size <bytes>] [--transport pipe|unix|tcp] [message_count]\n", argv[0]);
+            return -1;
+        }
+
+        // a reader that went away must fail the write, not end the process
+        signal(SIGPIPE, SIG_IGN);
+
+        const transport_t transports[] = {TRANSPORT_PIPE, TRANSPORT_UNIX, TRANSPORT_TCP};
+        bool              ran          = false;
+        for (u32 i = 0; i < sizeof(transports) / sizeof(transports[0]); ++i)
+        {
+            if (only != nullptr && strcmp(only, transport_name(transports[i])) != 0)
+                continue;
+            ran = true;
+            if (!bench_transport(transports[i], msg_count, msg_size))
+                return -1;
+        }
+        if (!ran)
+        {
+            printf("ipc-bench: unknown transport '%s'\n", only);
+            return -1;
+        }
+        if (json_path != nullptr && !write_results(json_path, msg_count))
+            return -1;
+        return 0;
+    }
+
+}  // namespace ncore
@@ -0,0 +1,157 @@
+#include "ccore/c_target.h"
+
+#include <string.h>
+#include <stdio.h>
+#include <cstdlib>
+
+namespace ncore
+{
+    // Input is one sample per line, "<nanoseconds>" or "<message size> <nanoseconds>" as written
+    // by 'cmmio-bench --samples', samples are reported per message size.
+    struct series_t
+    {
+        u32  msg_size;
+        u64* samples;
+        u32  count;
+        u32  capacity;
+    };
+
+    static series_t s_series[32];
+    static u32      s_num_series = 0;
+
+    static series_t* get_series(u32 msg_size)
+    {
+        for (u32 i = 0; i < s_num_series; ++i)
+        {
+            if (s_series[i].msg_size == msg_size)
+                return &s_series[i];
+        }
+        if (s_num_series >= sizeof(s_series) / sizeof(s_series[0]))
+            return nullptr;
+        series_t* s = &s_series[s_num_series++];
+        memset(s, 0, sizeof(*s));
+        s->msg_size = msg_size;
+        return s;
+    }
+
+    static void add_sample(series_t* s, u64 ns)
+    {
+        if (s->count == s->capacity)
+        {
+            s->capacity = (s->capacity == 0) ? 4096 : s->capacity * 2;
+            s->samples  = (u64*)realloc(s->samples, sizeof(u64) * s->capacity);
+        }
+        s->samples[s->count++] = ns;
+    }
+
+    static int cmp_u64(const void* a, const void* b)
+    {
+        const u64 x = *(const u64*)a;
+        const u64 y = *(const u64*)b;
+        return (x < y) ? -1 : ((x > y) ? 1 : 0);
+    }
+
+    // value at quantile q of the sorted samples (nearest rank)
+    static u64 percentile(const series_t* s, double q)
+    {
+        u64 rank = (u64)(q * (double)s->count);
+        if (rank >= s->count)
+            rank = s->count - 1;
+        return s->samples[rank];
+    }
+
+    // Buckets of powers of two, the bar shows the share of the samples in the bucket and the
+    // last column the cumulative percentile, in the spirit of the HdrHistogram output.
+    static void print_histogram(const series_t* s)
+    {
+        u32 i = 0;
+        for (u64 upper = 1; i < s->count; upper <<= 1)
+        {
+            u32 n = 0;
+            while (i < s->count && s->samples[i] < upper)
+            {
+                n++;
+                i++;
+            }
+            if (n == 0)
+                continue;
+
+            const double share = (double)n / (double)s->count;
+            char         bar[41];
+            u32          len = (u32)(share * 40.0 + 0.5);
+            if (len == 0)
+                len = 1;
+            memset(bar, '#', len);
+            bar[len] = 0;
+            printf("    [%10llu, %10llu) ns %9u %-40s %9.4f%%\n", (unsigned long long)(upper >> 1), (unsigned long long)upper, n, bar, 100.0 * (double)i / (double)s->count);
+        }
+    }
+
+    int AppMain(int argc, const char** argv)
+    {
+        bool        histogram = false;
+        const char* path      = nullptr;
+        for (int i = 1; i < argc; ++i)
+        {
+            if (strcmp(argv[i], "--histogram") == 0)
+                histogram = true;
+            else if (path == nullptr)
+                path = argv[i];
+        }
+        if (path == nullptr)
+        {
+            printf("Usage: %s [--histogram] <samples.txt>\n", argv[0]);
+            printf("Prints latency percentiles of the samples written by 'cmmio-bench --samples <samples.txt>', '-' reads stdin.\n");
+            return -1;
+        }
+
+        FILE* in = (strcmp(path, "-") == 0) ? stdin : fopen(path, "r");
+        if (in == nullptr)
+        {
+            printf("cmmio-latency-report: cannot open '%s'\n", path);
+            return -1;
+        }
+
+        char line[128];
+        u32  line_nr = 0;
+        bool ok      = true;
+        while (ok && fgets(line, sizeof(line), in) != nullptr)
+        {
+            line_nr++;
+            unsigned long long a = 0, b = 0;
+            const int          n = sscanf(line, "%llu %llu", &a, &b);
+            if (n <= 0)
+                continue;  // empty line
+
+            series_t* s = get_series((n == 2) ? (u32)a : 0);
+            if (s == nullptr)
+            {
+                printf("cmmio-latency-report: too many message sizes, line %u\n", line_nr);
+                ok = false;
+                break;
+            }
+            add_sample(s, (n == 2) ? (u64)b : (u64)a);
+        }
+        if (in != stdin)
+            fclose(in);
+
+        if (ok)
+        {
+            printf("%8s %10s %10s %10s %10s %10s %10s %10s %10s\n", "size", "samples", "min", "p50", "p90", "p99", "p99.9", "p99.99", "max");
+            for (u32 i = 0; i < s_num_series; ++i)
+            {
+                series_t* s = &s_series[i];
+                qsort(s->samples, s->count, sizeof(u64), cmp_u64);
+                printf("%8u %10u %10llu %10llu %10llu %10llu %10llu %10llu %10llu\n", s->msg_size, s->count, (unsigned long long)s->samples[0], (unsigned long long)percentile(s, 0.50), (unsigned long long)percentile(s, 0.90),
+                       (unsigned long long)percentile(s, 0.99), (unsigned long long)percentile(s, 0.999), (unsigned long long)percentile(s, 0.9999), (unsigned long long)s->samples[s->count - 1]);
+                if (histogram)
+                    print_histogram(s);
+            }
+        }
+
+        for (u32 i = 0; i < s_num_series; ++i)
+            free(s_series[i].samples);
+        return ok ? 0 : -1;
+    }
+
+}  // namespace ncore
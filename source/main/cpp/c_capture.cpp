@@ -0,0 +1,149 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_capture.h"
+
+#include <stdio.h>
+
+namespace ncore
+{
+    namespace ncapture
+    {
+        struct writer_t
+        {
+            alloc_t* m_allocator;
+            FILE*    m_file;
+        };
+
+        struct reader_t
+        {
+            alloc_t* m_allocator;
+            FILE*    m_file;
+            u8*      m_payload;  // grows to the largest frame read so far
+            u32      m_payload_cap;
+        };
+
+        i32 open_writer(alloc_t* allocator, const char* path, writer_t*& w)
+        {
+            w       = nullptr;
+            FILE* f = fopen(path, "wb");
+            if (f == nullptr)
+                return CAPTURE_ERR_OPEN;
+
+            const header_t header = {MMQ_CAPTURE_MAGIC, MMQ_CAPTURE_VERSION, 0};
+            if (fwrite(&header, sizeof(header), 1, f) != 1)
+            {
+                fclose(f);
+                return CAPTURE_ERR_WRITE;
+            }
+
+            w              = g_allocate_and_clear<writer_t>(allocator);
+            w->m_allocator = allocator;
+            w->m_file      = f;
+            return CAPTURE_OK;
+        }
+
+        i32 write_frame(writer_t* w, u64 time_ns, const void* payload, u32 len)
+        {
+            if (len > MMQ_CAPTURE_MAX_LEN)
+                return CAPTURE_ERR_FRAME;
+            const frame_t frame = {time_ns, len, 0};
+            if (fwrite(&frame, sizeof(frame), 1, w->m_file) != 1)
+                return CAPTURE_ERR_WRITE;
+            if (len > 0 && fwrite(payload, 1, len, w->m_file) != len)
+                return CAPTURE_ERR_WRITE;
+            return CAPTURE_OK;
+        }
+
+        void close_writer(writer_t*& w)
+        {
+            if (w)
+            {
+                fclose(w->m_file);
+                w->m_allocator->deallocate(w);
+                w = nullptr;
+            }
+        }
+
+        i32 open_reader(alloc_t* allocator, const char* path, reader_t*& r)
+        {
+            r       = nullptr;
+            FILE* f = fopen(path, "rb");
+            if (f == nullptr)
+                return CAPTURE_ERR_OPEN;
+
+            header_t header;
+            if (fread(&header, sizeof(header), 1, f) != 1 || header.m_magic != MMQ_CAPTURE_MAGIC)
+            {
+                fclose(f);
+                return CAPTURE_ERR_FORMAT;
+            }
+            if (header.m_version != MMQ_CAPTURE_VERSION)
+            {
+                fclose(f);
+                return CAPTURE_ERR_VERSION;
+            }
+
+            r              = g_allocate_and_clear<reader_t>(allocator);
+            r->m_allocator = allocator;
+            r->m_file      = f;
+            return CAPTURE_OK;
+        }
+
+        i32 read_frame(reader_t* r, frame_t& frame, const u8*& payload)
+        {
+            const size_t n = fread(&frame, 1, sizeof(frame), r->m_file);
+            if (n == 0)
+                return CAPTURE_END;
+            if (n != sizeof(frame))
+                return CAPTURE_ERR_TRUNCATED;
+
+            // the length comes from the file, a damaged frame must not make the reader allocate gigabytes
+            if (frame.m_len > MMQ_CAPTURE_MAX_LEN)
+                return CAPTURE_ERR_FRAME;
+            if (frame.m_len > r->m_payload_cap)
+            {
+                if (r->m_payload != nullptr)
+                    r->m_allocator->deallocate(r->m_payload);
+                r->m_payload     = (u8*)r->m_allocator->allocate(frame.m_len);
+                r->m_payload_cap = (r->m_payload != nullptr) ? frame.m_len : 0;
+                if (r->m_payload == nullptr)
+                    return CAPTURE_ERR_ALLOC;
+            }
+            if (frame.m_len > 0 && fread(r->m_payload, 1, frame.m_len, r->m_file) != frame.m_len)
+                return CAPTURE_ERR_TRUNCATED;
+
+            payload = r->m_payload;
+            return CAPTURE_OK;
+        }
+
+        void close_reader(reader_t*& r)
+        {
+            if (r)
+            {
+                fclose(r->m_file);
+                if (r->m_payload != nullptr)
+                    r->m_allocator->deallocate(r->m_payload);
+                r->m_allocator->deallocate(r);
+                r = nullptr;
+            }
+        }
+
+        const char* error_str(i32 result)
+        {
+            switch (result)
+            {
+                case CAPTURE_OK: return "Ok";
+                case CAPTURE_END: return "End of capture";
+                case CAPTURE_ERR_OPEN: return "Failed to open/create the capture file";
+                case CAPTURE_ERR_FORMAT: return "Not a capture file";
+                case CAPTURE_ERR_VERSION: return "Unsupported capture format version";
+                case CAPTURE_ERR_TRUNCATED: return "Capture file is truncated";
+                case CAPTURE_ERR_WRITE: return "Failed to write to the capture file";
+                case CAPTURE_ERR_FRAME: return "Capture frame is too large";
+                case CAPTURE_ERR_ALLOC: return "Failed to allocate the capture frame";
+                default: return "Unknown error code";
+            }
+        }
+
+    }  // namespace ncapture
+}  // namespace ncore
@@ -319,10 +319,10 @@ namespace ncore
                 return valid();
             }
 
-            bool create(const char* path, i32 flags, u64 size)
+            bool create(const char* path, i32 flags, u64 size, u32 mode = DEFAULT_MODE)
             {
                 close();
-                m_fd = ::open(path, flags, DEFAULT_MODE);
+                m_fd = ::open(path, flags, (mode_t)mode);
                 if (valid())
                 {
                     if (size > 0)
@@ -335,7 +335,7 @@ namespace ncore
             }
 
             bool create_ro(const char* path, u64 size) { return create(path, O_RDONLY | O_CREAT, size); }
-            bool create_rw(const char* path, u64 size) { return create(path, O_RDWR | O_CREAT, size); }
+            bool create_rw(const char* path, u64 size, u32 mode = DEFAULT_MODE) { return create(path, O_RDWR | O_CREAT, size, mode); }
 
             bool valid() const { return m_fd != INVALID_FILE_DESCRIPTOR; }
 
@@ -512,9 +512,11 @@ namespace ncore
             return false;
         }
 
-        bool create_rw(mappedfile_t* mf, const char* path, u64 size)
+        bool create_rw(mappedfile_t* mf, const char* path, u64 size) { return create_rw(mf, path, size, filedescr_t::DEFAULT_MODE); }
+
+        bool create_rw(mappedfile_t* mf, const char* path, u64 size, u32 mode)
         {
-            if (mf->m_file.create_rw(path, size))
+            if (mf->m_file.create_rw(path, size, mode))
             {
                 return mf->m_mapped.map_rw(nullptr, mf->m_file.size(), MAP_SHARED, mf->m_file, 0);
             }
@@ -2,6 +2,7 @@
 
 #include "cmmio/c_mmio.h"
 #include "cmmio/c_mmmq.h"
+#include "cmmio/c_version.h"
 
 #include <string.h>
 #include <errno.h>
@@ -41,7 +42,7 @@ namespace ncore
         struct index_header_t
         {
             u64   m_magic;        // MMQ_MAGIC_INDEX
-            u32   m_version;      // 1
+            u32   m_version;      // CMMIO_ABI_VERSION
             u32   m_align;        // 8
             seq_t m_next_seq;     // producer-only
             seq_t m_entry_count;  // mirror of next_seq (optional)
@@ -53,7 +54,7 @@ namespace ncore
         struct data_header_t
         {
             u64 m_magic;      // MMQ_MAGIC_DATA
-            u32 m_version;    // 1
+            u32 m_version;    // CMMIO_ABI_VERSION
             u32 m_align;      // 8
             u64 m_write_pos;  // producer-only, bytes
             u64 m_file_size;  // mapped payload bytes
@@ -75,7 +76,7 @@ namespace ncore
         struct control_header_t
         {
             u64   m_magic;                       // MMQ_MAGIC_CONTROL
-            u16   m_version;                     // 1
+            u16   m_version;                     // CMMIO_ABI_VERSION
             u16   m_align;                       // 8
             i16   m_max_consumers;               // maximum number of consumer slots
             u16   m_reserved0;                   // padding
@@ -147,9 +148,9 @@ namespace ncore
 
         // Named semaphore helpers (macOS semantics: leading '/', O_CREAT|O_EXCL atomic)
         // docs:  [1](https://developer.apple.com/library/archive/documentation/System/Conceptual/ManPages_iPhoneOS/man2/sem_open.2.html)
-        static sem_t* sem_create_exclusive(const char* name, i32 initial)
+        static sem_t* sem_create_exclusive(const char* name, i32 initial, u16 mode)
         {
-            sem_t* s = sem_open(name, O_CREAT | O_EXCL, 0666, initial);
+            sem_t* s = sem_open(name, O_CREAT | O_EXCL, (mode_t)mode, initial);
             if (s == SEM_FAILED)
             {
                 if (errno == EEXIST)
@@ -176,6 +177,9 @@ namespace ncore
         static inline const u8*            get_consumer_payload(const data_header_t* dh) { return (const u8*)dh + sizeof(data_header_t); }
         static inline consumer_slot_t*     get_slots(control_header_t* ch) { return (consumer_slot_t*)((u8*)ch + sizeof(control_header_t)); }
 
+        // True when all consumer slots of the header lie within the mapped control.mm
+        static inline bool slots_fit(const control_header_t* ch, int_t control_size) { return ch->m_max_consumers >= 0 && (sizeof(control_header_t) + (sizeof(consumer_slot_t) * ch->m_max_consumers)) <= (uint_t)control_size; }
+
         // ====== Construct/Destruct handle ======
         handle_t* create_handle(alloc_t* allocator)
         {
@@ -214,6 +218,7 @@ namespace ncore
             MMQ_ERR_DATA_EXTEND         = -11,
             MMQ_ERR_NO_MSG_AVAILABLE    = -12,
             MMQ_ERR_TIMEDOUT            = -13,
+            MMQ_ERR_NOT_ATTACHED        = -14,
         };
 
         // ====== Producer init ======
@@ -235,7 +240,7 @@ namespace ncore
             }
             else
             {
-                if (!nmmio::create_rw(h->m_index, index_path, config.index_initial_bytes))
+                if (!nmmio::create_rw(h->m_index, index_path, config.index_initial_bytes, config.mode))
                     return MMQ_ERR_INDEX_OPEN_RW;
 
                 h->m_producer.m_index_base = nmmio::address_rw(h->m_index);
@@ -244,7 +249,7 @@ namespace ncore
                 h->m_producer.m_ih = (index_header_t*)h->m_producer.m_index_base;
                 memset(h->m_producer.m_ih, 0, sizeof(index_header_t));
                 h->m_producer.m_ih->m_magic       = MMQ_MAGIC_INDEX;
-                h->m_producer.m_ih->m_version     = 1;
+                h->m_producer.m_ih->m_version     = CMMIO_ABI_VERSION;
                 h->m_producer.m_ih->m_align       = MMQ_ALIGN;
                 h->m_producer.m_ih->m_next_seq    = 0;
                 h->m_producer.m_ih->m_entry_count = 0;
@@ -262,7 +267,7 @@ namespace ncore
             }
             else
             {
-                if (!nmmio::create_rw(h->m_data, data_path, config.data_initial_bytes))
+                if (!nmmio::create_rw(h->m_data, data_path, config.data_initial_bytes, config.mode))
                     return MMQ_ERR_DATA_OPEN_RW;
 
                 h->m_producer.m_data_base = nmmio::address_rw(h->m_data);
@@ -271,7 +276,7 @@ namespace ncore
                 h->m_producer.m_dh = (data_header_t*)h->m_producer.m_data_base;
                 memset(h->m_producer.m_dh, 0, sizeof(data_header_t));
                 h->m_producer.m_dh->m_magic     = MMQ_MAGIC_DATA;
-                h->m_producer.m_dh->m_version   = 1;
+                h->m_producer.m_dh->m_version   = CMMIO_ABI_VERSION;
                 h->m_producer.m_dh->m_align     = MMQ_ALIGN;
                 h->m_producer.m_dh->m_write_pos = 0;
                 h->m_producer.m_dh->m_file_size = h->m_data_size - sizeof(data_header_t);
@@ -288,7 +293,7 @@ namespace ncore
                 int_t control_bytes = sizeof(control_header_t) + (sizeof(consumer_slot_t) * config.max_consumers);
                 control_bytes       = (control_bytes + (1024 - 1)) & ~(1024 - 1);  // align up to 1 KiB
 
-                if (!nmmio::create_rw(h->m_control, control_path, control_bytes))
+                if (!nmmio::create_rw(h->m_control, control_path, control_bytes, config.mode))
                     return MMQ_ERR_CONTROL_OPEN_RW;
             }
 
@@ -300,7 +305,7 @@ namespace ncore
             h->m_producer.m_ch = (control_header_t*)h->m_producer.m_control_base;
             memset(h->m_producer.m_ch, 0, h->m_control_size);
             h->m_producer.m_ch->m_magic         = MMQ_MAGIC_CONTROL;
-            h->m_producer.m_ch->m_version       = 1;
+            h->m_producer.m_ch->m_version       = CMMIO_ABI_VERSION;
             h->m_producer.m_ch->m_align         = MMQ_ALIGN;
             h->m_producer.m_ch->m_max_consumers = config.max_consumers;
             h->m_producer.m_ch->m_notify_seq    = 0;
@@ -310,8 +315,8 @@ namespace ncore
             strncpy(h->m_producer.m_ch->m_registry_lock_sem, reg_sem_name, sizeof(h->m_producer.m_ch->m_registry_lock_sem) - 1);
 
             // create/open semaphores
-            h->m_new_sem = (void*)sem_create_exclusive(h->m_producer.m_ch->m_new_entries_sem, 0);    // counting semaphore
-            h->m_reg_sem = (void*)sem_create_exclusive(h->m_producer.m_ch->m_registry_lock_sem, 1);  // acts as mutex
+            h->m_new_sem = (void*)sem_create_exclusive(h->m_producer.m_ch->m_new_entries_sem, 0, config.mode);    // counting semaphore
+            h->m_reg_sem = (void*)sem_create_exclusive(h->m_producer.m_ch->m_registry_lock_sem, 1, config.mode);  // acts as mutex
             if (!h->m_new_sem || !h->m_reg_sem)
                 return MMQ_ERR_SEMAPHORE_OPEN;
 
@@ -336,17 +341,27 @@ namespace ncore
             h->m_data_size    = nmmio::size(h->m_data);
             h->m_control_size = nmmio::size(h->m_control);
 
+            // a producer that died while creating the files can leave them shorter than their header
+            if (h->m_index_size < (int_t)sizeof(index_header_t))
+                return MMQ_ERR_INDEX_SANITY;
+            if (h->m_data_size < (int_t)sizeof(data_header_t))
+                return MMQ_ERR_DATA_SANITY;
+            if (h->m_control_size < (int_t)sizeof(control_header_t))
+                return MMQ_ERR_CONTROL_SANITY;
+
             h->m_consumer.m_ih = (index_header_t*)h->m_consumer.m_index_base;
             h->m_consumer.m_dh = (data_header_t*)h->m_consumer.m_data_base;
             h->m_consumer.m_ch = (control_header_t*)h->m_consumer.m_control_base;
 
             // sanity
-            if (h->m_consumer.m_ih->m_magic != MMQ_MAGIC_INDEX || h->m_consumer.m_ih->m_version != 1 || h->m_consumer.m_ih->m_align != MMQ_ALIGN)
-                return MMQ_ERR_INDEX_OPEN_RW;
-            if (h->m_consumer.m_dh->m_magic != MMQ_MAGIC_DATA || h->m_consumer.m_dh->m_version != 1 || h->m_consumer.m_dh->m_align != MMQ_ALIGN)
-                return MMQ_ERR_DATA_OPEN_RW;
-            if (h->m_consumer.m_ch->m_magic != MMQ_MAGIC_CONTROL || h->m_consumer.m_ch->m_version != 1 || h->m_consumer.m_ch->m_align != MMQ_ALIGN)
-                return MMQ_ERR_CONTROL_OPEN_RW;
+            if (h->m_consumer.m_ih->m_magic != MMQ_MAGIC_INDEX || h->m_consumer.m_ih->m_version != CMMIO_ABI_VERSION || h->m_consumer.m_ih->m_align != MMQ_ALIGN)
+                return MMQ_ERR_INDEX_SANITY;
+            if (h->m_consumer.m_dh->m_magic != MMQ_MAGIC_DATA || h->m_consumer.m_dh->m_version != CMMIO_ABI_VERSION || h->m_consumer.m_dh->m_align != MMQ_ALIGN)
+                return MMQ_ERR_DATA_SANITY;
+            if (h->m_consumer.m_ch->m_magic != MMQ_MAGIC_CONTROL || h->m_consumer.m_ch->m_version != CMMIO_ABI_VERSION || h->m_consumer.m_ch->m_align != MMQ_ALIGN)
+                return MMQ_ERR_CONTROL_SANITY;
+            if (!slots_fit(h->m_consumer.m_ch, h->m_control_size))
+                return MMQ_ERR_CONTROL_SANITY;
 
             // open semaphores by names in control.mm
             h->m_new_sem = (void*)sem_open_existing(h->m_consumer.m_ch->m_new_entries_sem);
@@ -358,6 +373,121 @@ namespace ncore
             return MMQ_ERR_OK;
         }
 
+        // ====== Inspector attach ======
+        i32 attach_inspector(handle_t* h, const char* index_path, const char* data_path, const char* control_path)
+        {
+            if (!nmmio::open_ro(h->m_index, index_path))
+                return MMQ_ERR_INDEX_OPEN_RW;
+            if (!nmmio::open_ro(h->m_data, data_path))
+                return MMQ_ERR_DATA_OPEN_RW;
+            if (!nmmio::open_ro(h->m_control, control_path))
+                return MMQ_ERR_CONTROL_OPEN_RW;
+
+            h->m_consumer.m_index_base   = nmmio::address_ro(h->m_index);
+            h->m_consumer.m_data_base    = nmmio::address_ro(h->m_data);
+            h->m_consumer.m_control_base = nullptr;
+
+            h->m_index_size   = nmmio::size(h->m_index);
+            h->m_data_size    = nmmio::size(h->m_data);
+            h->m_control_size = nmmio::size(h->m_control);
+
+            // a producer that died while creating the files can leave them shorter than their header
+            if (h->m_index_size < (int_t)sizeof(index_header_t))
+                return MMQ_ERR_INDEX_SANITY;
+            if (h->m_data_size < (int_t)sizeof(data_header_t))
+                return MMQ_ERR_DATA_SANITY;
+            if (h->m_control_size < (int_t)sizeof(control_header_t))
+                return MMQ_ERR_CONTROL_SANITY;
+
+            h->m_consumer.m_ih = (const index_header_t*)h->m_consumer.m_index_base;
+            h->m_consumer.m_dh = (const data_header_t*)h->m_consumer.m_data_base;
+            h->m_consumer.m_ch = (control_header_t*)nmmio::address_ro(h->m_control);
+
+            // sanity
+            if (h->m_consumer.m_ih->m_magic != MMQ_MAGIC_INDEX || h->m_consumer.m_ih->m_version != CMMIO_ABI_VERSION || h->m_consumer.m_ih->m_align != MMQ_ALIGN)
+                return MMQ_ERR_INDEX_SANITY;
+            if (h->m_consumer.m_dh->m_magic != MMQ_MAGIC_DATA || h->m_consumer.m_dh->m_version != CMMIO_ABI_VERSION || h->m_consumer.m_dh->m_align != MMQ_ALIGN)
+                return MMQ_ERR_DATA_SANITY;
+            if (h->m_consumer.m_ch->m_magic != MMQ_MAGIC_CONTROL || h->m_consumer.m_ch->m_version != CMMIO_ABI_VERSION || h->m_consumer.m_ch->m_align != MMQ_ALIGN)
+                return MMQ_ERR_CONTROL_SANITY;
+            if (!slots_fit(h->m_consumer.m_ch, h->m_control_size))
+                return MMQ_ERR_CONTROL_SANITY;
+
+            h->m_new_sem     = NULL;
+            h->m_reg_sem     = NULL;
+            h->m_is_producer = false;
+            return MMQ_ERR_OK;
+        }
+
+        // ====== Inspection ======
+        static void get_views(handle_t* h, const index_header_t*& ih, const data_header_t*& dh, const control_header_t*& ch)
+        {
+            if (h->m_is_producer)
+            {
+                ih = h->m_producer.m_ih;
+                dh = h->m_producer.m_dh;
+                ch = h->m_producer.m_ch;
+            }
+            else
+            {
+                ih = h->m_consumer.m_ih;
+                dh = h->m_consumer.m_dh;
+                ch = h->m_consumer.m_ch;
+            }
+        }
+
+        i32 inspect(handle_t* h, info_t& info)
+        {
+            const index_header_t*   ih;
+            const data_header_t*    dh;
+            const control_header_t* ch;
+            get_views(h, ih, dh, ch);
+            if (ih == nullptr || dh == nullptr || ch == nullptr)
+                return MMQ_ERR_NOT_ATTACHED;
+
+            info.index_version   = ih->m_version;
+            info.data_version    = dh->m_version;
+            info.control_version = ch->m_version;
+            info.max_consumers   = (u16)ch->m_max_consumers;
+            info.next_seq        = ih->m_next_seq;
+            info.index_capacity  = (h->m_index_size - sizeof(index_header_t)) / sizeof(index_entry_t);
+            info.data_write_pos  = dh->m_write_pos;
+            info.data_capacity   = dh->m_file_size;
+            info.notify_seq      = ch->m_notify_seq;
+            strncpy(info.new_entries_sem, ch->m_new_entries_sem, sizeof(info.new_entries_sem) - 1);
+            strncpy(info.registry_lock_sem, ch->m_registry_lock_sem, sizeof(info.registry_lock_sem) - 1);
+            info.new_entries_sem[sizeof(info.new_entries_sem) - 1]     = 0;
+            info.registry_lock_sem[sizeof(info.registry_lock_sem) - 1] = 0;
+            return MMQ_ERR_OK;
+        }
+
+        i32 inspect_consumer(handle_t* h, i32 slot_index, consumer_info_t& info)
+        {
+            const index_header_t*   ih;
+            const data_header_t*    dh;
+            const control_header_t* ch;
+            get_views(h, ih, dh, ch);
+            if (ch == nullptr)
+                return MMQ_ERR_NOT_ATTACHED;
+            if (slot_index < 0 || slot_index >= ch->m_max_consumers)
+                return MMQ_ERR_CONTROL_SANITY;
+
+            const consumer_slot_t* s = &get_slots((control_header_t*)ch)[slot_index];
+            info.last_update_ns      = s->m_last_update_ns;
+            info.last_seq            = s->m_last_seq;
+            info.active              = s->m_active != 0;
+            strncpy(info.name, s->m_name, sizeof(info.name) - 1);
+            info.name[sizeof(info.name) - 1] = 0;
+            return MMQ_ERR_OK;
+        }
+
+        u64 clock_ns()
+        {
+            struct timespec ts;
+            clock_gettime(CLOCK_MONOTONIC, &ts);
+            return (u64)ts.tv_sec * 1000000000ull + (u64)ts.tv_nsec;
+        }
+
         // ====== Consumer registration ======
         i32 register_consumer(handle_t* h, const char* name, u32 start_seq, i32& slot)
         {
@@ -377,7 +507,8 @@ namespace ncore
                 {
                     if (strncmp(s[i].m_name, name, sizeof(s[i].m_name)) == 0)
                     {
-                        slot = (i32)i;
+                        s[i].m_last_update_ns = clock_ns();
+                        slot                  = (i32)i;
                         break;
                     }
                 }
@@ -392,8 +523,9 @@ namespace ncore
                 {
                     if (!s[i].m_active)
                     {
-                        s[i].m_active   = 1;
-                        s[i].m_last_seq = start_seq;
+                        s[i].m_active         = 1;
+                        s[i].m_last_seq       = start_seq;
+                        s[i].m_last_update_ns = clock_ns();
                         strncpy(s[i].m_name, name, sizeof(s[i].m_name) - 1);
                         slot = (i32)i;
                         break;
@@ -405,10 +537,73 @@ namespace ncore
             return (slot < 0) ? MMQ_ERR_CONSUMER_SLOTS_FULL : MMQ_ERR_OK;
         }
 
+        i32 release_consumer(handle_t* h, i32 slot_index)
+        {
+            if (h->m_is_producer || h->m_reg_sem == NULL)
+                return MMQ_ERR_NOT_ATTACHED;
+            if (slot_index < 0 || slot_index >= h->m_consumer.m_ch->m_max_consumers)
+                return MMQ_ERR_CONTROL_SANITY;
+
+            sem_t* lock = (sem_t*)h->m_reg_sem;
+            if (sem_wait(lock) != 0)
+                return MMQ_ERR_REGISTRY_LOCK;
+
+            consumer_slot_t* s  = &get_slots(h->m_consumer.m_ch)[slot_index];
+            s->m_active         = 0;
+            s->m_last_seq       = 0;
+            s->m_last_update_ns = 0;
+            memset(s->m_name, 0, sizeof(s->m_name));
+
+            sem_post(lock);  // unlock
+            return MMQ_ERR_OK;
+        }
+
+        i32 consumer_heartbeat(handle_t* h, i32 slot_index)
+        {
+            if (h->m_is_producer || h->m_reg_sem == NULL)
+                return MMQ_ERR_NOT_ATTACHED;
+            if (slot_index < 0 || slot_index >= h->m_consumer.m_ch->m_max_consumers)
+                return MMQ_ERR_CONTROL_SANITY;
+
+            // a single aligned store, read without the registry lock by inspectors
+            get_slots(h->m_consumer.m_ch)[slot_index].m_last_update_ns = clock_ns();
+            return MMQ_ERR_OK;
+        }
+
+        // ====== Producer reserve ======
+        i32 reserve(handle_t* h, uint_t index_bytes, uint_t data_bytes)
+        {
+            producer_t* p = &h->m_producer;
+            if (!h->m_is_producer)
+                return MMQ_ERR_NOT_ATTACHED;
+
+            if ((int_t)index_bytes > h->m_index_size)
+            {
+                if (!nmmio::extend_size(h->m_index, (u64)index_bytes))
+                    return MMQ_ERR_INDEX_EXTEND;
+                h->m_index_size = nmmio::size(h->m_index);
+                p->m_index_base = nmmio::address_rw(h->m_index);
+                p->m_ih         = (index_header_t*)p->m_index_base;
+            }
+
+            if ((int_t)data_bytes > h->m_data_size)
+            {
+                if (!nmmio::extend_size(h->m_data, (u64)data_bytes))
+                    return MMQ_ERR_DATA_EXTEND;
+                h->m_data_size       = nmmio::size(h->m_data);
+                p->m_data_base       = nmmio::address_rw(h->m_data);
+                p->m_dh              = (data_header_t*)p->m_data_base;
+                p->m_dh->m_file_size = h->m_data_size - sizeof(data_header_t);
+            }
+            return MMQ_ERR_OK;
+        }
+
         static inline u64 align_up_u64(u64 x, u64 a) { return (x + (a - 1)) & ~(a - 1); }
 
         // ====== Producer publish ======
-        i32 publish(handle_t* h, const void* msg, u32 len)
+        // Writes the payload and the index entry of message @seq, the message is not visible to
+        // consumers until m_next_seq is moved past it.
+        static i32 append(handle_t* h, const void* msg, u32 len, seq_t seq)
         {
             producer_t* p = &h->m_producer;
 
@@ -421,9 +616,13 @@ namespace ncore
             {
                 // Grow 10% of current file size
                 // e.g. 10MB -> 11MB, 100MB -> 110MB
-                int_t new_size = (int_t)(h->m_data_size * 11 / 10);
+                // and at least as far as the message needs, it can be larger than the 10%
+                int_t       new_size = (int_t)(h->m_data_size * 11 / 10);
+                const int_t needed   = (int_t)(sizeof(data_header_t) + end);
+                if (new_size < needed)
+                    new_size = needed;
                 if (!nmmio::extend_size(h->m_data, new_size))
-                    return -1;
+                    return MMQ_ERR_DATA_EXTEND;
                 h->m_data_size       = nmmio::size(h->m_data);
                 p->m_data_base       = nmmio::address_rw(h->m_data);
                 p->m_dh              = (data_header_t*)p->m_data_base;
@@ -434,10 +633,8 @@ namespace ncore
             memcpy(payload + pos, msg, len);
             if (span > len)
                 memset(payload + pos + len, 0, (int_t)(span - len));
-            p->m_dh->m_write_pos = end;
 
             // Ensure index has room
-            const seq_t seq              = p->m_ih->m_next_seq;
             const int_t need_index_bytes = sizeof(index_header_t) + ((int_t)(seq + 1) * sizeof(index_entry_t));
             if (need_index_bytes > h->m_index_size)
             {
@@ -448,24 +645,61 @@ namespace ncore
 
                 if (!nmmio::extend_size(h->m_index, new_size))
                     return MMQ_ERR_INDEX_EXTEND;
-
-                p->m_ih = (index_header_t*)p->m_index_base;
+                h->m_index_size = nmmio::size(h->m_index);
+                p->m_index_base = nmmio::address_rw(h->m_index);
+                p->m_ih         = (index_header_t*)p->m_index_base;
             }
+            p->m_dh->m_write_pos = end;
 
             // Index entry: PENDING -> READY (single producer, no lock needed)
             index_entry_t* e = &get_producer_entries(p->m_ih)[seq];
             e->m_seq         = seq;
-            e->m_off8        = (u32)(pos >> 3);
+            e->m_off8        = pos >> 3;
             e->m_len         = len;
+            return MMQ_ERR_OK;
+        }
 
-            p->m_ih->m_next_seq    = seq + 1;
-            p->m_ih->m_entry_count = p->m_ih->m_next_seq;
+        // Makes every message before @next_seq visible and wakes up the consumers.
+        static void commit(handle_t* h, seq_t next_seq)
+        {
+            producer_t* p          = &h->m_producer;
+            p->m_ih->m_next_seq    = next_seq;
+            p->m_ih->m_entry_count = next_seq;
 
             // Notify consumers
             p->m_ch->m_notify_seq++;
             sem_t* ns = (sem_t*)h->m_new_sem;
             sem_post(ns);
+        }
 
+        i32 publish(handle_t* h, const void* msg, u32 len)
+        {
+            const seq_t seq    = h->m_producer.m_ih->m_next_seq;
+            const i32   result = append(h, msg, len, seq);
+            if (result != MMQ_ERR_OK)
+                return result;
+            commit(h, seq + 1);
+            return MMQ_ERR_OK;
+        }
+
+        i32 publish_batch(handle_t* h, const void* const* msgs, const u32* lens, u32 count)
+        {
+            if (count == 0)
+                return MMQ_ERR_OK;
+
+            // a failed message gives back the data space of the ones appended before it
+            const seq_t seq       = h->m_producer.m_ih->m_next_seq;
+            const u64   write_pos = h->m_producer.m_dh->m_write_pos;
+            for (u32 i = 0; i < count; ++i)
+            {
+                const i32 result = append(h, msgs[i], lens[i], seq + i);
+                if (result != MMQ_ERR_OK)
+                {
+                    h->m_producer.m_dh->m_write_pos = write_pos;
+                    return result;
+                }
+            }
+            commit(h, seq + count);
             return MMQ_ERR_OK;
         }
 
@@ -474,21 +708,53 @@ namespace ncore
         {
             consumer_slot_t* self = &get_slots(h->m_consumer.m_ch)[slot_index];
             const seq_t      nseq = h->m_consumer.m_ih->m_next_seq;
-            if (self->m_last_seq < nseq)
+            const seq_t      nent = (seq_t)((h->m_index_size - sizeof(index_header_t)) / sizeof(index_entry_t));
+            if (self->m_last_seq < nseq && self->m_last_seq < nent)
             {
                 const index_entry_t* e   = &get_consumer_entries(h->m_consumer.m_ih)[self->m_last_seq];
                 const u64            off = ((u64)e->m_off8) << 3;
-                msg_data                 = get_consumer_payload(h->m_consumer.m_dh) + off;
-                msg_len                  = e->m_len;
 
-                self->m_last_seq++;
-                return true;
+                // the payload of a message written by a producer that crashed can be cut off,
+                // such a message is never delivered and the cursor stays in front of it
+                if ((sizeof(data_header_t) + off + e->m_len) <= (u64)h->m_data_size)
+                {
+                    msg_data = get_consumer_payload(h->m_consumer.m_dh) + off;
+                    msg_len  = e->m_len;
+
+                    self->m_last_seq++;
+                    return true;
+                }
             }
             msg_data = nullptr;
             msg_len  = 0;
             return false;
         }
 
+        u32 consumer_drain_batch(handle_t* h, i32 slot_index, u8 const** msg_data, u32* msg_len, u32 max_count)
+        {
+            consumer_slot_t* self = &get_slots(h->m_consumer.m_ch)[slot_index];
+            const seq_t      nseq = h->m_consumer.m_ih->m_next_seq;
+            const seq_t      nent = (seq_t)((h->m_index_size - sizeof(index_header_t)) / sizeof(index_entry_t));
+
+            // the cursor in control.mm is written once for the whole batch
+            seq_t seq   = self->m_last_seq;
+            u32   count = 0;
+            while (count < max_count && seq < nseq && seq < nent)
+            {
+                const index_entry_t* e   = &get_consumer_entries(h->m_consumer.m_ih)[seq];
+                const u64            off = ((u64)e->m_off8) << 3;
+                if ((sizeof(data_header_t) + off + e->m_len) > (u64)h->m_data_size)
+                    break;  // see consumer_drain
+
+                msg_data[count] = get_consumer_payload(h->m_consumer.m_dh) + off;
+                msg_len[count]  = e->m_len;
+                count++;
+                seq++;
+            }
+            self->m_last_seq = seq;
+            return count;
+        }
+
         // ====== Waits ======
         bool wait_for_new(handle_t* h)
         {
@@ -497,22 +763,31 @@ namespace ncore
             return (result == 0);
         }
 
-        bool wait_for_new_timeout(handle_t* h, u32 timeout_us)
+        bool wait_for_new_timeout(handle_t* h, u32 timeout_us) { return wait_for_new_cancel(h, timeout_us, nullptr); }
+
+        bool wait_for_new_cancel(handle_t* h, u32 timeout_us, const volatile i32* cancel)
         {
-            // Emulate: loop trywait + nanosleep
+            // Emulate: loop trywait + nanosleep, a timeout of 0 still checks once
             sem_t*    ns        = (sem_t*)h->m_new_sem;
             const u32 slice_us  = 500;
             u32       waited_us = 0;
-            while (waited_us < timeout_us)
+            do
             {
                 if (sem_trywait(ns) == 0)
                     return true;
+                if (cancel != nullptr && *cancel != 0)
+                {
+                    errno = ECANCELED;
+                    return false;
+                }
+                if (waited_us >= timeout_us)
+                    break;
                 struct timespec ts;
                 ts.tv_sec  = 0;
                 ts.tv_nsec = slice_us * 1000;
                 nanosleep(&ts, NULL);
                 waited_us += slice_us;
-            }
+            } while (true);
             errno = ETIMEDOUT;
             return false;
         }
@@ -568,6 +843,7 @@ namespace ncore
                 case MMQ_ERR_DATA_EXTEND: return "Failed to extend data.mm size";
                 case MMQ_ERR_NO_MSG_AVAILABLE: return "No message available to consume";
                 case MMQ_ERR_TIMEDOUT: return "Timed out waiting for new message";
+                case MMQ_ERR_NOT_ATTACHED: return "Handle is not attached to a channel";
                 default: return "Unknown error code";
             }
         }
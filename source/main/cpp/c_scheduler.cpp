@@ -0,0 +1,83 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_scheduler.h"
+
+namespace ncore
+{
+    namespace nscheduler
+    {
+        struct channel_t
+        {
+            nmmmq::handle_t* m_handle;
+            i32              m_slot;
+            u32              m_weight;
+        };
+
+        struct scheduler_t
+        {
+            alloc_t*   m_allocator;
+            channel_t* m_channels;
+            u32        m_max_channels;
+            u32        m_num_channels;
+            u32        m_current;  // the channel whose turn it is
+            u32        m_credit;   // messages left in the turn of m_current, 0 = turn not started
+        };
+
+        scheduler_t* create_scheduler(alloc_t* allocator, u32 max_channels)
+        {
+            scheduler_t* s    = g_allocate_and_clear<scheduler_t>(allocator);
+            s->m_allocator    = allocator;
+            s->m_channels     = (channel_t*)allocator->allocate(sizeof(channel_t) * max_channels);  // filled by add_channel
+            s->m_max_channels = max_channels;
+            return s;
+        }
+
+        void destroy_scheduler(scheduler_t*& s)
+        {
+            if (s)
+            {
+                s->m_allocator->deallocate(s->m_channels);
+                s->m_allocator->deallocate(s);
+                s = nullptr;
+            }
+        }
+
+        i32 add_channel(scheduler_t* s, nmmmq::handle_t* h, i32 slot_index, u32 weight)
+        {
+            if (s->m_num_channels >= s->m_max_channels || weight == 0)
+                return -1;
+            channel_t& c = s->m_channels[s->m_num_channels];
+            c.m_handle   = h;
+            c.m_slot     = slot_index;
+            c.m_weight   = weight;
+            return (i32)s->m_num_channels++;
+        }
+
+        bool next(scheduler_t* s, i32& channel, u8 const*& msg_data, u32& msg_len)
+        {
+            // every channel that has nothing ends its turn, so after one visit of each channel
+            // without a message there is nothing to deliver
+            for (u32 visited = 0; visited < s->m_num_channels; ++visited)
+            {
+                const channel_t& c = s->m_channels[s->m_current];
+                if (s->m_credit == 0)
+                    s->m_credit = c.m_weight;
+
+                if (nmmmq::consumer_drain(c.m_handle, c.m_slot, msg_data, msg_len))
+                {
+                    channel = (i32)s->m_current;
+                    if (--s->m_credit == 0)
+                        s->m_current = (s->m_current + 1) % s->m_num_channels;
+                    return true;
+                }
+
+                s->m_credit  = 0;
+                s->m_current = (s->m_current + 1) % s->m_num_channels;
+            }
+            msg_data = nullptr;
+            msg_len  = 0;
+            return false;
+        }
+
+    }  // namespace nscheduler
+}  // namespace ncore
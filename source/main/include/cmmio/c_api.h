@@ -0,0 +1,26 @@
+#ifndef __CMMIO_API_H__
+#define __CMMIO_API_H__
+#include "ccore/c_target.h"
+#ifdef USE_PRAGMA_ONCE
+#    pragma once
+#endif
+
+// CMMIO_API marks the public functions of the library.
+// - static library (default): CMMIO_API is empty
+// - shared library: define CMMIO_DLL for the library and its users, and CMMIO_EXPORTS
+//   only when building the library itself
+#if defined(CMMIO_DLL)
+#    if defined(TARGET_PC)
+#        if defined(CMMIO_EXPORTS)
+#            define CMMIO_API __declspec(dllexport)
+#        else
+#            define CMMIO_API __declspec(dllimport)
+#        endif
+#    else
+#        define CMMIO_API __attribute__((visibility("default")))
+#    endif
+#else
+#    define CMMIO_API
+#endif
+
+#endif  // __CMMIO_API_H__
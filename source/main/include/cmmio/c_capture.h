@@ -0,0 +1,74 @@
+#ifndef __CMMIO_CAPTURE_H__
+#define __CMMIO_CAPTURE_H__
+#include "ccore/c_target.h"
+#ifdef USE_PRAGMA_ONCE
+#    pragma once
+#endif
+
+#include "cmmio/c_api.h"
+
+namespace ncore
+{
+    class alloc_t;
+
+    // Summary:
+    // File format of a recorded message stream, shared by cmmio-replay, cmmio-convert and
+    // cmmio-trace (little-endian, as written by the recording host):
+    //   header: u64 magic, u32 version, u32 reserved
+    //   frame:  u64 time_ns (relative to the first frame), u32 len, u32 reserved, u8 payload[len], len <= MMQ_CAPTURE_MAX_LEN
+    namespace ncapture
+    {
+#define MMQ_CAPTURE_MAGIC   0xCA97F11E0000CA9ULL
+#define MMQ_CAPTURE_VERSION 1u
+#define MMQ_CAPTURE_MAX_LEN (64u * 1024u * 1024u)  // largest payload of a frame
+
+        struct header_t
+        {
+            u64 m_magic;
+            u32 m_version;
+            u32 m_reserved;
+        };
+
+        struct frame_t
+        {
+            u64 m_time_ns;
+            u32 m_len;
+            u32 m_reserved;
+        };
+
+        enum EResult
+        {
+            CAPTURE_OK            = 0,
+            CAPTURE_END           = 1,   // read_frame: no more frames
+            CAPTURE_ERR_OPEN      = -1,  // the file cannot be opened or created
+            CAPTURE_ERR_FORMAT    = -2,  // the file does not start with a capture header
+            CAPTURE_ERR_VERSION   = -3,  // the capture was written with another version of the format
+            CAPTURE_ERR_TRUNCATED = -4,  // the file ends in the middle of a frame
+            CAPTURE_ERR_WRITE     = -5,  // writing to the file failed, e.g. the disk is full
+            CAPTURE_ERR_FRAME     = -6,  // the payload of a frame is larger than MMQ_CAPTURE_MAX_LEN
+            CAPTURE_ERR_ALLOC     = -7,  // the payload of a frame cannot be allocated
+        };
+
+        struct writer_t;
+        struct reader_t;
+
+        // Creates (or truncates) the capture file at path and writes the header.
+        CMMIO_API i32 open_writer(alloc_t* allocator, const char* path, writer_t*& w);
+
+        // Appends one frame, time_ns is relative to the first frame of the capture.
+        CMMIO_API i32  write_frame(writer_t* w, u64 time_ns, const void* payload, u32 len);
+        CMMIO_API void close_writer(writer_t*& w);
+
+        // Opens the capture file at path and verifies its header.
+        CMMIO_API i32 open_reader(alloc_t* allocator, const char* path, reader_t*& r);
+
+        // Reads the next frame, payload points to memory owned by the reader that stays valid until
+        // the next read_frame or close_reader. Returns CAPTURE_END after the last frame.
+        CMMIO_API i32  read_frame(reader_t* r, frame_t& frame, const u8*& payload);
+        CMMIO_API void close_reader(reader_t*& r);
+
+        CMMIO_API const char* error_str(i32 result);
+    }  // namespace ncapture
+}  // namespace ncore
+
+#endif  // __CMMIO_CAPTURE_H__
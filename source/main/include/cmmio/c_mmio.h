@@ -6,6 +6,7 @@
 #endif
 
 #include "ccore/c_stream.h"
+#include "cmmio/c_api.h"
 
 namespace ncore
 {
@@ -14,22 +15,23 @@ namespace ncore
     namespace nmmio
     {
         struct mappedfile_t;
-        void allocate(alloc_t* allocator, mappedfile_t*& out_mf);
-        void deallocate(alloc_t* allocator, mappedfile_t* mf);
+        CMMIO_API void allocate(alloc_t* allocator, mappedfile_t*& out_mf);
+        CMMIO_API void deallocate(alloc_t* allocator, mappedfile_t* mf);
 
-        bool        exists(mappedfile_t* mf, const char* path);
-        bool        open_rw(mappedfile_t* mf, const char* path);
-        bool        open_ro(mappedfile_t* mf, const char* path);
-        bool        create_rw(mappedfile_t* mf, const char* path, u64 size);
-        bool        create_ro(mappedfile_t* mf, const char* path, u64 size);
-        bool        close(mappedfile_t* mf);
-        bool        is_writeable(mappedfile_t* mf);
-        bool        extend_size(mappedfile_t* mf, u64 new_size);
-        void*       address_rw(mappedfile_t* mf);
-        const void* address_ro(mappedfile_t* mf);
-        u64         size(mappedfile_t* mf);
-        void        sync(mappedfile_t* mf);
-        void        sync(mappedfile_t* mf, u64 offset, u64 bytes);
+        CMMIO_API bool        exists(mappedfile_t* mf, const char* path);
+        CMMIO_API bool        open_rw(mappedfile_t* mf, const char* path);
+        CMMIO_API bool        open_ro(mappedfile_t* mf, const char* path);
+        CMMIO_API bool        create_rw(mappedfile_t* mf, const char* path, u64 size);
+        CMMIO_API bool        create_rw(mappedfile_t* mf, const char* path, u64 size, u32 mode);  // POSIX mode bits of a new file, the umask applies
+        CMMIO_API bool        create_ro(mappedfile_t* mf, const char* path, u64 size);
+        CMMIO_API bool        close(mappedfile_t* mf);
+        CMMIO_API bool        is_writeable(mappedfile_t* mf);
+        CMMIO_API bool        extend_size(mappedfile_t* mf, u64 new_size);
+        CMMIO_API void*       address_rw(mappedfile_t* mf);
+        CMMIO_API const void* address_ro(mappedfile_t* mf);
+        CMMIO_API u64         size(mappedfile_t* mf);
+        CMMIO_API void        sync(mappedfile_t* mf);
+        CMMIO_API void        sync(mappedfile_t* mf, u64 offset, u64 bytes);
 
     }  // namespace nmmio
 }  // namespace ncore
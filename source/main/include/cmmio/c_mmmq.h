@@ -5,6 +5,8 @@
 #    pragma once
 #endif
 
+#include "cmmio/c_api.h"
+
 namespace ncore
 {
     // Summary:
@@ -24,48 +26,114 @@ namespace ncore
                 : index_initial_bytes(index_bytes)
                 , data_initial_bytes(data_bytes)
                 , max_consumers(max_consumers)
+                , mode(0666)
             {
             }
             uint_t index_initial_bytes;
             uint_t data_initial_bytes;
             u16    max_consumers;
+            u16    mode;  // POSIX mode bits of the files and semaphores init_producer creates, the umask applies
+        };
+
+        // Snapshot of the channel state as stored in index.mm, data.mm and control.mm.
+        struct info_t
+        {
+            u32  index_version;
+            u32  data_version;
+            u16  control_version;
+            u16  max_consumers;
+            u64  next_seq;           // sequence number of the next message to be published
+            u64  index_capacity;     // number of entries that fit in index.mm
+            u64  data_write_pos;     // bytes of payload written
+            u64  data_capacity;      // bytes of payload that fit in data.mm
+            u64  notify_seq;         // incremented per publish
+            char new_entries_sem[52];
+            char registry_lock_sem[52];
+        };
+
+        // Snapshot of one consumer slot in control.mm.
+        struct consumer_info_t
+        {
+            u64  last_update_ns;  // clock_ns() of the registration or the last heartbeat
+            u64  last_seq;        // sequence number of the next message this consumer will read
+            bool active;
+            char name[44];
         };
 
         // Create handle
-        handle_t* create_handle(alloc_t* allocator);
-        void      destroy_handle(handle_t*& h);
+        CMMIO_API handle_t* create_handle(alloc_t* allocator);
+        CMMIO_API void      destroy_handle(handle_t*& h);
 
         // Producer opens mmaps (RW) and semaphores (new_sem, reg_sem).
-        i32 init_producer(handle_t* h, const config_t& config, const char* index_path, const char* data_path, const char* control_path, const char* new_sem_name, const char* reg_sem_name);
+        CMMIO_API i32 init_producer(handle_t* h, const config_t& config, const char* index_path, const char* data_path, const char* control_path, const char* new_sem_name, const char* reg_sem_name);
 
         // Producer publishes one message (append-only data + index two-phase commit).
-        i32 publish(handle_t* h, const void* msg, u32 len);
+        CMMIO_API i32 publish(handle_t* h, const void* msg, u32 len);
+
+        // Producer publishes @count messages with a single update of the index header and a single
+        // notification, either all of them become visible to consumers or none of them.
+        CMMIO_API i32 publish_batch(handle_t* h, const void* const* msgs, const u32* lens, u32 count);
 
         // Consumer attaches: index/data (RO), control (RW); opens named semaphores.
-        i32 attach_consumer(handle_t* h, const char* index_path, const char* data_path, const char* control_path);
+        CMMIO_API i32 attach_consumer(handle_t* h, const char* index_path, const char* data_path, const char* control_path);
+
+        // Inspector attaches: index/data/control (RO), no semaphores, cannot register or drain.
+        CMMIO_API i32 attach_inspector(handle_t* h, const char* index_path, const char* data_path, const char* control_path);
+
+        // Read the channel header state, works on producer, consumer and inspector handles.
+        CMMIO_API i32 inspect(handle_t* h, info_t& info);
+
+        // Read consumer slot @slot_index (0 <= slot_index < info_t::max_consumers).
+        CMMIO_API i32 inspect_consumer(handle_t* h, i32 slot_index, consumer_info_t& info);
 
         // Consumer registers (protected by registry_lock semaphore), returns slot index >= 0 or -1 when full.
         // @name: maximum length is 44 chars (including null terminator)
-        i32 register_consumer(handle_t* h, const char* name, u32 start_seq, i32& slot);
+        CMMIO_API i32 register_consumer(handle_t* h, const char* name, u32 start_seq, i32& slot);
+
+        // Release consumer slot @slot_index (protected by registry_lock semaphore), e.g. the slot of a
+        // consumer that crashed, the slot can then be registered again under any name.
+        CMMIO_API i32 release_consumer(handle_t* h, i32 slot_index);
+
+        // Consumer stores the current clock_ns() in its slot, a consumer that calls this regularly
+        // (e.g. on every wakeup) can be detected as dead by inspectors once it stops doing so.
+        CMMIO_API i32 consumer_heartbeat(handle_t* h, i32 slot_index);
+
+        // Monotonic time in nanoseconds, the time base of consumer_info_t::last_update_ns, the
+        // same for all processes on a host.
+        CMMIO_API u64 clock_ns();
+
+        // Producer grows index.mm and data.mm to at least @index_bytes and @data_bytes, files are never shrunk.
+        // Consumers that are already attached keep seeing the old size.
+        CMMIO_API i32 reserve(handle_t* h, uint_t index_bytes, uint_t data_bytes);
 
         // Consumer drains all available READY entries, keep calling until none left.
         // Function will return false when no more messages are available, true otherwise.
         // No memory allocation or copy is performed, the user has to process the message immediately
         // or copy it elsewhere, there is no guarantee the message will remain valid after any
         // call to this API.
-        bool consumer_drain(handle_t* h, i32 slot_index, u8 const*& msg_data, u32& msg_len);
+        CMMIO_API bool consumer_drain(handle_t* h, i32 slot_index, u8 const*& msg_data, u32& msg_len);
+
+        // Consumer drains up to @max_count messages into @msg_data/@msg_len and moves its cursor
+        // once, returns the number of messages. The same rules as for consumer_drain apply.
+        CMMIO_API u32 consumer_drain_batch(handle_t* h, i32 slot_index, u8 const** msg_data, u32* msg_len, u32 max_count);
 
         // Blocking wait for new entries (sem_wait).
-        bool wait_for_new(handle_t* h);
+        CMMIO_API bool wait_for_new(handle_t* h);
+
+        // Emulated timed wait (macOS lacks sem_timedwait): trywait + sleeps for timeout_us, a
+        // timeout_us of 0 checks once without sleeping.
+        CMMIO_API bool wait_for_new_timeout(handle_t* h, u32 timeout_us);
 
-        // Emulated timed wait (macOS lacks sem_timedwait): trywait + sleeps for timeout_us.
-        bool wait_for_new_timeout(handle_t* h, u32 timeout_us);
+        // Timed wait that can also be cancelled, returns false with errno = ECANCELED as soon as
+        // *@cancel is non-zero (e.g. set by another thread or a signal handler to stop a consumer
+        // loop). @cancel is checked before every sleep, so it takes effect within 500 us.
+        CMMIO_API bool wait_for_new_cancel(handle_t* h, u32 timeout_us, const volatile i32* cancel);
 
         // Close/unmap files and close semaphores. (Producer may also sem_unlink by names if desired.)
-        void close_handle(handle_t* h);
+        CMMIO_API void close_handle(handle_t* h);
 
         // Get error string for return codes.
-        const char* error_str(i32 result);
+        CMMIO_API const char* error_str(i32 result);
     }  // namespace nmmmq
 }  // namespace ncore
 
@@ -0,0 +1,39 @@
+#ifndef __CMMIO_SCHEDULER_H__
+#define __CMMIO_SCHEDULER_H__
+#include "ccore/c_target.h"
+#ifdef USE_PRAGMA_ONCE
+#    pragma once
+#endif
+
+#include "cmmio/c_mmmq.h"
+
+namespace ncore
+{
+    class alloc_t;
+
+    // Summary:
+    // Weighted round-robin over the consumer slots of several channels, for a single consumer that
+    // must not let a chatty channel starve the others. Every round a channel delivers up to its
+    // weight in messages, a channel without messages gives up the rest of its turn.
+    // The scheduler only drains, waiting for new messages stays with the caller (e.g. a poll with
+    // wait_for_new_timeout on one of the channels).
+    namespace nscheduler
+    {
+        struct scheduler_t;
+
+        // Room for @max_channels channels.
+        CMMIO_API scheduler_t* create_scheduler(alloc_t* allocator, u32 max_channels);
+        CMMIO_API void         destroy_scheduler(scheduler_t*& s);
+
+        // Adds the registered consumer @slot_index of consumer handle @h with @weight messages per
+        // round (>= 1), returns the index of the channel or -1 when full or @weight is 0.
+        CMMIO_API i32 add_channel(scheduler_t* s, nmmmq::handle_t* h, i32 slot_index, u32 weight);
+
+        // Drains the next message in schedule order and sets @channel to the index returned by
+        // add_channel. Returns false when none of the channels has a message. The same rules as for
+        // nmmmq::consumer_drain apply to @msg_data.
+        CMMIO_API bool next(scheduler_t* s, i32& channel, u8 const*& msg_data, u32& msg_len);
+    }  // namespace nscheduler
+}  // namespace ncore
+
+#endif  // __CMMIO_SCHEDULER_H__
@@ -0,0 +1,17 @@
+#ifndef __CMMIO_VERSION_H__
+#define __CMMIO_VERSION_H__
+#ifdef USE_PRAGMA_ONCE
+#    pragma once
+#endif
+
+// Keep in sync with version/abi_version in package/package.go, GetPackageChecked reports a mismatch
+#define CMMIO_VERSION_MAJOR 0
+#define CMMIO_VERSION_MINOR 1
+#define CMMIO_VERSION_PATCH 0
+#define CMMIO_VERSION       "0.1.0"
+
+// Version of the on-disk layout of index.mm, data.mm and control.mm, incremented whenever
+// processes built against different versions can no longer share a channel.
+#define CMMIO_ABI_VERSION 1
+
+#endif  // __CMMIO_VERSION_H__
@@ -0,0 +1,98 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_mmmq.h"
+
+#include <unistd.h>
+#include <stdio.h>
+#include <cstdlib>
+
+class malloc_based_alloc_t : public ncore::alloc_t
+{
+public:
+    void* v_allocate(ncore::u32 size, ncore::u32 alignment) { return malloc(size); }
+    void  v_deallocate(void* ptr) { free(ptr); }
+};
+
+namespace ncore
+{
+    static malloc_based_alloc_t s_malloc_based_alloc;
+
+    // Writes one sample of the channel in the Prometheus text exposition format.
+    static void write_metrics(FILE* f, nmmmq::handle_t* h, const nmmmq::info_t& info)
+    {
+        fprintf(f, "# TYPE cmmio_published_messages counter\n");
+        fprintf(f, "cmmio_published_messages %llu\n", (unsigned long long)info.next_seq);
+        fprintf(f, "# TYPE cmmio_index_capacity_entries gauge\n");
+        fprintf(f, "cmmio_index_capacity_entries %llu\n", (unsigned long long)info.index_capacity);
+        fprintf(f, "# TYPE cmmio_data_used_bytes gauge\n");
+        fprintf(f, "cmmio_data_used_bytes %llu\n", (unsigned long long)info.data_write_pos);
+        fprintf(f, "# TYPE cmmio_data_capacity_bytes gauge\n");
+        fprintf(f, "cmmio_data_capacity_bytes %llu\n", (unsigned long long)info.data_capacity);
+        fprintf(f, "# TYPE cmmio_consumer_lag_messages gauge\n");
+        for (i32 i = 0; i < (i32)info.max_consumers; ++i)
+        {
+            nmmmq::consumer_info_t ci;
+            if (nmmmq::inspect_consumer(h, i, ci) != 0 || !ci.active)
+                continue;
+            const u64 lag = (info.next_seq > ci.last_seq) ? (info.next_seq - ci.last_seq) : 0;
+            fprintf(f, "cmmio_consumer_lag_messages{consumer=\"%s\",slot=\"%d\"} %llu\n", ci.name, i, (unsigned long long)lag);
+        }
+    }
+
+    // Samples the channel statistics every interval and (re)writes them to a text file, which
+    // can be served as-is or picked up by a node_exporter textfile collector. When no output
+    // path is given the samples are printed to stdout.
+    int AppMain(int argc, const char** argv)
+    {
+        const char* output_path = (argc >= 2) ? argv[1] : nullptr;
+        const u32   interval_ms = (argc >= 3) ? (u32)atoi(argv[2]) : 1000;
+
+        nmmmq::handle_t* h = nmmmq::create_handle(&s_malloc_based_alloc);
+
+        i32 result = nmmmq::attach_inspector(h, "index.mm", "data.mm", "control.mm");
+        if (result != 0)
+        {
+            printf("cmmio-metrics: %s\n", nmmmq::error_str(result));
+            printf("Usage: %s [output_path] [interval_ms]\n", argv[0]);
+            nmmmq::destroy_handle(h);
+            return -1;
+        }
+
+        char tmp_path[512];
+        if (output_path != nullptr)
+            snprintf(tmp_path, sizeof(tmp_path), "%s.tmp", output_path);
+
+        for (;;)
+        {
+            nmmmq::info_t info;
+            result = nmmmq::inspect(h, info);
+            if (result != 0)
+                break;
+
+            if (output_path == nullptr)
+            {
+                write_metrics(stdout, h, info);
+                fflush(stdout);
+            }
+            else
+            {
+                // write to a temporary file and rename, readers never see a partial sample
+                FILE* f = fopen(tmp_path, "w");
+                if (f == nullptr)
+                {
+                    printf("cmmio-metrics: cannot write '%s'\n", tmp_path);
+                    break;
+                }
+                write_metrics(f, h, info);
+                fclose(f);
+                rename(tmp_path, output_path);
+            }
+
+            usleep(interval_ms * 1000);
+        }
+
+        nmmmq::destroy_handle(h);
+        return result == 0 ? 0 : -1;
+    }
+
+}  // namespace ncore
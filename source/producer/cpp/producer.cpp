@@ -22,33 +22,251 @@ namespace ncore
     static malloc_based_alloc_t s_malloc_based_alloc;
     static alloc_t*             s_allocator = &s_malloc_based_alloc;
 
-    static i32 producer()
+    // Producer settings, see data/producer.schema.json for a description of each setting.
+    struct settings_t
+    {
+        char index_path[256];
+        char data_path[256];
+        char control_path[256];
+        char new_sem_name[52];
+        char reg_sem_name[52];
+        u32  index_kb;
+        u32  data_kb;
+        u32  max_consumers;
+        i32  num_messages;
+        i32  interval_ms;
+    };
+
+    static void default_settings(settings_t& s)
+    {
+        strcpy(s.index_path, "index.mm");
+        strcpy(s.data_path, "data.mm");
+        strcpy(s.control_path, "control.mm");
+        strcpy(s.new_sem_name, "mmq_new_entries_sem");
+        strcpy(s.reg_sem_name, "mmq_registry_lock_sem");
+        s.index_kb      = 1024;
+        s.data_kb       = 10 * 1024;
+        s.max_consumers = 16;
+        s.num_messages  = 1200;  // 60 seconds, 20 messages per second
+        s.interval_ms   = 50;
+    }
+
+    // Minimal reader for the flat JSON object of a producer config, see data/producer.schema.json.
+    struct json_reader_t
+    {
+        const char* m_path;
+        const char* m_cur;
+        const char* m_begin;
+    };
+
+    static bool json_error(json_reader_t& r, const char* what)
+    {
+        i32 line = 1;
+        for (const char* c = r.m_begin; c < r.m_cur; ++c)
+            line += (*c == '\n') ? 1 : 0;
+        printf("producer: %s:%d: %s\n", r.m_path, line, what);
+        return false;
+    }
+
+    static void json_skip_ws(json_reader_t& r)
+    {
+        while (*r.m_cur == ' ' || *r.m_cur == '\t' || *r.m_cur == '\r' || *r.m_cur == '\n')
+            r.m_cur++;
+    }
+
+    static bool json_expect(json_reader_t& r, char c)
+    {
+        json_skip_ws(r);
+        if (*r.m_cur != c)
+        {
+            char what[32];
+            snprintf(what, sizeof(what), "expected '%c'", c);
+            return json_error(r, what);
+        }
+        r.m_cur++;
+        return true;
+    }
+
+    // Reads a string with the escapes \" \\ \/ \n and \t, it has to fit in @dst_size - 1 characters.
+    static bool json_string(json_reader_t& r, char* dst, u32 dst_size)
+    {
+        if (!json_expect(r, '"'))
+            return false;
+        u32 len = 0;
+        while (*r.m_cur != '"')
+        {
+            char c = *r.m_cur++;
+            if (c == 0 || c == '\n')
+                return json_error(r, "unterminated string");
+            if (c == '\\')
+            {
+                c = *r.m_cur++;
+                if (c == 'n')
+                    c = '\n';
+                else if (c == 't')
+                    c = '\t';
+                else if (c != '"' && c != '\\' && c != '/')
+                    return json_error(r, "unsupported escape in string");
+            }
+            if (len + 1 >= dst_size)
+                return json_error(r, "string is too long");
+            dst[len++] = c;
+        }
+        r.m_cur++;
+        dst[len] = 0;
+        return true;
+    }
+
+    static bool json_integer(json_reader_t& r, u32 min, u32 max, i64& value)
+    {
+        json_skip_ws(r);
+        if (*r.m_cur < '0' || *r.m_cur > '9')
+            return json_error(r, "expected a non-negative integer");
+        char*     end    = nullptr;
+        const i64 number = (i64)strtoll(r.m_cur, &end, 10);
+        r.m_cur          = end;
+        if (*r.m_cur == '.' || *r.m_cur == 'e' || *r.m_cur == 'E')
+            return json_error(r, "expected an integer");
+        if (number < (i64)min || number > (i64)max)
+            return json_error(r, "integer out of range");
+        value = number;
+        return true;
+    }
+
+    // Reads the config object, every member is optional. Unknown members, values of the wrong
+    // type and values outside the range of the schema are errors.
+    static bool parse_settings(json_reader_t& r, settings_t& s)
+    {
+        if (!json_expect(r, '{'))
+            return false;
+        json_skip_ws(r);
+        if (*r.m_cur == '}')
+        {
+            r.m_cur++;
+            return true;
+        }
+
+        while (true)
+        {
+            char key[32];
+            if (!json_string(r, key, sizeof(key)) || !json_expect(r, ':'))
+                return false;
+
+            i64  number = 0;
+            bool ok     = false;
+            if (strcmp(key, "index_path") == 0)
+                ok = json_string(r, s.index_path, sizeof(s.index_path));
+            else if (strcmp(key, "data_path") == 0)
+                ok = json_string(r, s.data_path, sizeof(s.data_path));
+            else if (strcmp(key, "control_path") == 0)
+                ok = json_string(r, s.control_path, sizeof(s.control_path));
+            else if (strcmp(key, "new_sem_name") == 0)
+                ok = json_string(r, s.new_sem_name, sizeof(s.new_sem_name));
+            else if (strcmp(key, "reg_sem_name") == 0)
+                ok = json_string(r, s.reg_sem_name, sizeof(s.reg_sem_name));
+            else if (strcmp(key, "index_kb") == 0)
+            {
+                ok         = json_integer(r, 1, 0x3FFFFF, number);
+                s.index_kb = (u32)number;
+            }
+            else if (strcmp(key, "data_kb") == 0)
+            {
+                ok        = json_integer(r, 1, 0x3FFFFF, number);
+                s.data_kb = (u32)number;
+            }
+            else if (strcmp(key, "max_consumers") == 0)
+            {
+                ok              = json_integer(r, 1, 0xFFFF, number);
+                s.max_consumers = (u32)number;
+            }
+            else if (strcmp(key, "num_messages") == 0)
+            {
+                ok             = json_integer(r, 0, 0x7FFFFFFF, number);
+                s.num_messages = (i32)number;
+            }
+            else if (strcmp(key, "interval_ms") == 0)
+            {
+                ok            = json_integer(r, 0, 0x7FFFFFFF, number);
+                s.interval_ms = (i32)number;
+            }
+            else
+            {
+                char what[64];
+                snprintf(what, sizeof(what), "unknown setting '%s'", key);
+                return json_error(r, what);
+            }
+            if (!ok)
+                return false;
+
+            json_skip_ws(r);
+            if (*r.m_cur == '}')
+            {
+                r.m_cur++;
+                break;
+            }
+            if (!json_expect(r, ','))
+                return false;
+        }
+
+        json_skip_ws(r);
+        if (*r.m_cur != 0)
+            return json_error(r, "unexpected content after the config object");
+        return true;
+    }
+
+    static bool load_settings(const char* path, settings_t& s)
+    {
+        FILE* f = fopen(path, "rb");
+        if (f == nullptr)
+        {
+            printf("producer: cannot open config '%s'\n", path);
+            return false;
+        }
+
+        // a config is a few hundred bytes, anything larger is not one
+        char         text[8192];
+        const size_t n    = fread(text, 1, sizeof(text) - 1, f);
+        const bool   full = feof(f) != 0;
+        fclose(f);
+        if (!full)
+        {
+            printf("producer: config '%s' is too large\n", path);
+            return false;
+        }
+        text[n] = 0;
+
+        json_reader_t r;
+        r.m_path  = path;
+        r.m_cur   = text;
+        r.m_begin = text;
+        return parse_settings(r, s);
+    }
+
+    static i32 producer(const settings_t& s)
     {
         nmmmq::handle_t* h = nmmmq::create_handle(s_allocator);
 
-        const char* index_path   = "index.mm";
-        const char* data_path    = "data.mm";
-        const char* control_path = "control.mm";
-        const char* new_sem_name = "mmq_new_entries_sem";
-        const char* reg_sem_name = "mmq_registry_lock_sem";
+        const char* index_path   = s.index_path;
+        const char* data_path    = s.data_path;
+        const char* control_path = s.control_path;
+        const i32   num_messages = s.num_messages;
+        const i32   interval_ms  = s.interval_ms;
 
-        nmmmq::config_t config(1 * cMB, 10 * cMB, 16);
+        nmmmq::config_t config((uint_t)s.index_kb * cKB, (uint_t)s.data_kb * cKB, (u16)s.max_consumers);
 
         printf("initializing producer with index_path=%s, data_path=%s, control_path=%s\n", index_path, data_path, control_path);
 
-        i32 result = nmmmq::init_producer(h, config, index_path, data_path, control_path, new_sem_name, reg_sem_name);
+        i32 result = nmmmq::init_producer(h, config, index_path, data_path, control_path, s.new_sem_name, s.reg_sem_name);
         if (result < 0)
         {
             printf("producer: init failed (err = %s)\n", nmmmq::error_str(result));
             return 1;
         }
 
-        const i32 num_seconds = 60;
-
-        printf("producing messages for %d seconds...\n", num_seconds);
+        printf("producing %d messages, one every %d ms...\n", num_messages, interval_ms);
 
         char msg[128];
-        for (int i = 0; i < (num_seconds * 20); ++i)
+        for (int i = 0; i < num_messages; ++i)
         {
             int n = snprintf(msg, sizeof(msg), "msg %d (pid=%d)", i, getpid());
             if (n < 0)
@@ -69,85 +287,55 @@ namespace ncore
             printf("\rproduced %d messages...", i + 1);
             fflush(stdout);
 
-            usleep(50 * 1000);
+            usleep(interval_ms * 1000);
         }
 
-        printf("done producing messages.\n");
+        printf("\ndone producing messages.\n");
 
         nmmmq::close_handle(h);
         return 0;
     }
 
-    static int consumer(const char* consumer_name, u32 start_seq = 0)
+    // Parses a non-negative decimal number, anything else (e.g. a name) is rejected.
+    static bool parse_number(const char* str, i32& value)
     {
-        nmmmq::handle_t* h = nmmmq::create_handle(s_allocator);
-
-        const char* index_path   = "index.mm";
-        const char* data_path    = "data.mm";
-        const char* control_path = "control.mm";
-
-        printf("attaching consumer '%s' with start_seq=%u to index_path=%s, data_path=%s, control_path=%s\n", consumer_name, start_seq, index_path, data_path, control_path);
-
-        i32 result = nmmmq::attach_consumer(h, index_path, data_path, control_path);
-        if (result != 0)
-        {
-            printf("consumer: attach failed (err = %s)\n", nmmmq::error_str(result));
-            return 1;
-        }
-
-        printf("registering consumer '%s' with start_seq=%u\n", consumer_name, start_seq);
-
-        i32 slot;
-        result = nmmmq::register_consumer(h, consumer_name, start_seq, slot);
-        if (result<0)
-        {
-            printf("consumer: register failed (err = %s)\n", nmmmq::error_str(result));
-            nmmmq::close_handle(h);
-            return 1;
-        }
-
-        printf("starting to consume messages...\n");
-        for (;;)
-        {
-            const u8* msg_data;
-            u32       msg_len;
-            if (!nmmmq::consumer_drain(h, slot, msg_data, msg_len))
-            {
-                if (!nmmmq::wait_for_new(h))
-                {
-                    printf("consumer: wait failed (errno=%d)\n", errno);
-                    break;
-                }
-            }
-            else
-            {
-                if (msg_data != nullptr)
-                    printf("consumer '%s' got message: %.*s\n", consumer_name, msg_len, msg_data);
-
-                usleep(80 * 1000);
-            }
-        }
+        if (str[0] < '0' || str[0] > '9')
+            return false;
+        char*      end    = nullptr;
+        const long number = strtol(str, &end, 10);
+        if (*end != '\0' || number > 0x7FFFFFFF)
+            return false;
+        value = (i32)number;
+        return true;
+    }
 
-        return 0;
+    static int usage(const char* app)
+    {
+        printf("Usage: %s [num_messages] [interval_ms]\n", app);
+        printf("       %s --config <file>\n", app);
+        return -1;
     }
 
     int AppMain(int argc, const char** argv)
     {
-        if (argc >= 2 && strcmp(argv[1], "producer") == 0)
-        {
-            producer();
-        }
-        else if (argc >= 2 && strcmp(argv[1], "consumer") == 0)
+        settings_t settings;
+        default_settings(settings);
+
+        if (argc >= 3 && strcmp(argv[1], "--config") == 0)
         {
-            const char* consumer_name = (argc >= 3) ? argv[2] : "consumer1";
-            u32         start_seq     = (argc >= 4) ? (u32)atoi(argv[3]) : 0;
-            consumer(consumer_name, start_seq);
+            if (!load_settings(argv[2], settings))
+                return -1;
         }
         else
         {
-            printf("Usage: %s [producer|consumer]\n", argv[0]);
-            return -1;
+            if (argc >= 2 && !parse_number(argv[1], settings.num_messages))
+                return usage(argv[0]);
+            if (argc >= 3 && !parse_number(argv[2], settings.interval_ms))
+                return usage(argv[0]);
         }
+
+        if (producer(settings) != 0)
+            return -1;
         return 0;
     }
 
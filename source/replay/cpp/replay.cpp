@@ -0,0 +1,182 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_capture.h"
+#include "cmmio/c_mmmq.h"
+
+#include <unistd.h>
+#include <string.h>
+#include <stdio.h>
+#include <cstdlib>
+#include <time.h>
+
+class malloc_based_alloc_t : public ncore::alloc_t
+{
+public:
+    void* v_allocate(ncore::u32 size, ncore::u32 alignment) { return malloc(size); }
+    void  v_deallocate(void* ptr) { free(ptr); }
+};
+
+namespace ncore
+{
+    static malloc_based_alloc_t s_malloc_based_alloc;
+    static alloc_t*             s_allocator = &s_malloc_based_alloc;
+
+    static u64 now_ns()
+    {
+        struct timespec ts;
+        clock_gettime(CLOCK_MONOTONIC, &ts);
+        return (u64)ts.tv_sec * 1000000000ull + (u64)ts.tv_nsec;
+    }
+
+    static void sleep_ns(u64 ns)
+    {
+        struct timespec ts;
+        ts.tv_sec  = (time_t)(ns / 1000000000ull);
+        ts.tv_nsec = (long)(ns % 1000000000ull);
+        nanosleep(&ts, NULL);
+    }
+
+    static i32 record(const char* capture_path, const char* index_path, const char* data_path, const char* control_path, u32 num_seconds)
+    {
+        nmmmq::handle_t* h = nmmmq::create_handle(s_allocator);
+
+        i32 slot   = -1;
+        i32 result = nmmmq::attach_consumer(h, index_path, data_path, control_path);
+        if (result == 0)
+            result = nmmmq::register_consumer(h, "cmmio-replay", 0, slot);
+        if (result != 0)
+        {
+            printf("replay: attach failed (err = %s)\n", nmmmq::error_str(result));
+            nmmmq::destroy_handle(h);
+            return 1;
+        }
+
+        ncapture::writer_t* w = nullptr;
+        result                = ncapture::open_writer(s_allocator, capture_path, w);
+        if (result != 0)
+        {
+            printf("replay: cannot create '%s' (err = %s)\n", capture_path, ncapture::error_str(result));
+            nmmmq::destroy_handle(h);
+            return 1;
+        }
+
+        printf("recording to '%s' for %u seconds...\n", capture_path, num_seconds);
+
+        const u64 begin    = now_ns();
+        const u64 end      = begin + (u64)num_seconds * 1000000000ull;
+        u64       first_ns = 0;
+        u32       count    = 0;
+        while (result == 0 && now_ns() < end)
+        {
+            const u8* msg_data;
+            u32       msg_len;
+            if (!nmmmq::consumer_drain(h, slot, msg_data, msg_len))
+            {
+                nmmmq::wait_for_new_timeout(h, 100 * 1000);
+                continue;
+            }
+
+            const u64 t = now_ns();
+            if (count == 0)
+                first_ns = t;
+
+            result = ncapture::write_frame(w, t - first_ns, msg_data, msg_len);
+            if (result == 0)
+            {
+                printf("\rrecorded %u messages...", ++count);
+                fflush(stdout);
+            }
+        }
+
+        if (result != 0)
+            printf("\nreplay: recording failed (err = %s)\n", ncapture::error_str(result));
+        else
+            printf("\ndone recording %u messages.\n", count);
+
+        ncapture::close_writer(w);
+        nmmmq::destroy_handle(h);
+        return result != 0 ? 1 : 0;
+    }
+
+    static i32 replay(const char* capture_path, const char* index_path, const char* data_path, const char* control_path, double speed)
+    {
+        ncapture::reader_t* r       = nullptr;
+        i32                 capture = ncapture::open_reader(s_allocator, capture_path, r);
+        if (capture != 0)
+        {
+            printf("replay: cannot open '%s' (err = %s)\n", capture_path, ncapture::error_str(capture));
+            return 1;
+        }
+
+        nmmmq::handle_t* h = nmmmq::create_handle(s_allocator);
+
+        nmmmq::config_t config(1 * cMB, 10 * cMB, 16);
+
+        i32 result = nmmmq::init_producer(h, config, index_path, data_path, control_path, "mmq_new_entries_sem", "mmq_registry_lock_sem");
+        if (result < 0)
+        {
+            printf("replay: init failed (err = %s)\n", nmmmq::error_str(result));
+            nmmmq::destroy_handle(h);
+            ncapture::close_reader(r);
+            return 1;
+        }
+
+        u32       count = 0;
+        const u64 begin = now_ns();
+
+        ncapture::frame_t frame;
+        const u8*         payload = nullptr;
+        while (result >= 0 && (capture = ncapture::read_frame(r, frame, payload)) == ncapture::CAPTURE_OK)
+        {
+            // speed == 0 replays as fast as possible
+            if (speed > 0.0)
+            {
+                const u64 due     = begin + (u64)((double)frame.m_time_ns / speed);
+                const u64 current = now_ns();
+                if (due > current)
+                    sleep_ns(due - current);
+            }
+
+            result = nmmmq::publish(h, payload, frame.m_len);
+            if (result >= 0)
+            {
+                printf("\rreplayed %u messages...", ++count);
+                fflush(stdout);
+            }
+        }
+
+        if (result < 0)
+            printf("\nreplay: publish failed (err = %s)\n", nmmmq::error_str(result));
+        else if (capture != ncapture::CAPTURE_END)
+            printf("\nreplay: reading '%s' failed after %u messages (err = %s)\n", capture_path, count, ncapture::error_str(capture));
+        else
+            printf("\ndone replaying %u messages.\n", count);
+
+        ncapture::close_reader(r);
+        nmmmq::destroy_handle(h);
+        return (result < 0 || capture != ncapture::CAPTURE_END) ? 1 : 0;
+    }
+
+    int AppMain(int argc, const char** argv)
+    {
+        const char* index_path   = "index.mm";
+        const char* data_path    = "data.mm";
+        const char* control_path = "control.mm";
+
+        if (argc >= 3 && strcmp(argv[1], "record") == 0)
+        {
+            const u32 num_seconds = (argc >= 4) ? (u32)atoi(argv[3]) : 60;
+            return record(argv[2], index_path, data_path, control_path, num_seconds) == 0 ? 0 : -1;
+        }
+        else if (argc >= 3 && strcmp(argv[1], "replay") == 0)
+        {
+            const double speed = (argc >= 4) ? atof(argv[3]) : 1.0;
+            return replay(argv[2], index_path, data_path, control_path, speed) == 0 ? 0 : -1;
+        }
+
+        printf("Usage: %s record <capture.bin> [seconds]\n", argv[0]);
+        printf("       %s replay <capture.bin> [speed, 1.0 = original, 0 = as fast as possible]\n", argv[0]);
+        return -1;
+    }
+
+}  // namespace ncore
@@ -0,0 +1,184 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_mmmq.h"
+
+#include <unistd.h>
+#include <string.h>
+#include <stdio.h>
+#include <cstdlib>
+#include <time.h>
+#include <sys/types.h>
+#include <sys/wait.h>
+
+class malloc_based_alloc_t : public ncore::alloc_t
+{
+public:
+    void* v_allocate(ncore::u32 size, ncore::u32 alignment) { return malloc(size); }
+    void  v_deallocate(void* ptr) { free(ptr); }
+};
+
+namespace ncore
+{
+    static malloc_based_alloc_t s_malloc_based_alloc;
+    static alloc_t*             s_allocator = &s_malloc_based_alloc;
+
+    static const char* s_index_path   = "soak_index.mm";
+    static const char* s_data_path    = "soak_data.mm";
+    static const char* s_control_path = "soak_control.mm";
+    static const char* s_new_sem_name = "mmq_soak_new_entries_sem";
+    static const char* s_reg_sem_name = "mmq_soak_registry_lock_sem";
+
+    // Every message starts with its sequence number, the final message carries s_end_marker.
+    static const u64 s_end_marker = 0xFFFFFFFFFFFFFFFFull;
+
+    static u64 now_ns()
+    {
+        struct timespec ts;
+        clock_gettime(CLOCK_MONOTONIC, &ts);
+        return (u64)ts.tv_sec * 1000000000ull + (u64)ts.tv_nsec;
+    }
+
+    // Message length varies with the sequence number so that the data cursor does not
+    // advance in a regular pattern.
+    static u32 msg_len_for(u64 seq) { return 8 + (u32)((seq * 2654435761ull) % 249); }
+
+    // Runs in a child process, returns the exit code of that process.
+    static int consumer(i32 index)
+    {
+        nmmmq::handle_t* h = nmmmq::create_handle(s_allocator);
+
+        char name[32];
+        snprintf(name, sizeof(name), "soak%d", index);
+
+        i32 slot   = -1;
+        i32 result = nmmmq::attach_consumer(h, s_index_path, s_data_path, s_control_path);
+        if (result == 0)
+            result = nmmmq::register_consumer(h, name, 0, slot);
+        if (result != 0)
+        {
+            printf("soak: consumer %d attach failed (err = %s)\n", index, nmmmq::error_str(result));
+            nmmmq::destroy_handle(h);
+            return 1;
+        }
+
+        u64 expected = 0;
+        int exitcode = 0;
+        for (;;)
+        {
+            const u8* msg_data;
+            u32       msg_len;
+            if (!nmmmq::consumer_drain(h, slot, msg_data, msg_len))
+            {
+                nmmmq::wait_for_new_timeout(h, 10 * 1000);
+                continue;
+            }
+
+            u64 seq;
+            memcpy(&seq, msg_data, sizeof(seq));
+            if (seq == s_end_marker)
+                break;
+
+            if (seq != expected || msg_len != msg_len_for(seq))
+            {
+                printf("soak: consumer %d expected seq %llu (len %u), got seq %llu (len %u)\n", index, (unsigned long long)expected, msg_len_for(expected), (unsigned long long)seq, msg_len);
+                exitcode = 1;
+                break;
+            }
+            for (u32 i = sizeof(seq); i < msg_len; ++i)
+            {
+                if (msg_data[i] != (u8)(seq + i))
+                {
+                    printf("soak: consumer %d payload corruption in seq %llu at byte %u\n", index, (unsigned long long)seq, i);
+                    exitcode = 1;
+                    break;
+                }
+            }
+            if (exitcode != 0)
+                break;
+            expected++;
+        }
+
+        if (exitcode == 0)
+            printf("soak: consumer %d verified %llu messages\n", index, (unsigned long long)expected);
+
+        nmmmq::destroy_handle(h);
+        return exitcode;
+    }
+
+    int AppMain(int argc, const char** argv)
+    {
+        const u32 num_consumers = (argc >= 2) ? (u32)atoi(argv[1]) : 4;
+        const u32 num_seconds   = (argc >= 3) ? (u32)atoi(argv[2]) : 3600;
+        if (num_consumers == 0 || num_consumers > 16)
+        {
+            printf("Usage: %s [num_consumers (1-16)] [num_seconds]\n", argv[0]);
+            return -1;
+        }
+
+        unlink(s_index_path);
+        unlink(s_data_path);
+        unlink(s_control_path);
+
+        nmmmq::handle_t* h = nmmmq::create_handle(s_allocator);
+
+        nmmmq::config_t config(1 * cMB, 16 * cMB, 16);
+
+        i32 result = nmmmq::init_producer(h, config, s_index_path, s_data_path, s_control_path, s_new_sem_name, s_reg_sem_name);
+        if (result < 0)
+        {
+            printf("soak: init failed (err = %s)\n", nmmmq::error_str(result));
+            nmmmq::destroy_handle(h);
+            return -1;
+        }
+
+        pid_t pids[16];
+        for (u32 i = 0; i < num_consumers; ++i)
+        {
+            pids[i] = fork();
+            if (pids[i] == 0)
+                _exit(consumer((i32)i));
+        }
+
+        printf("soak: running %u consumers for %u seconds...\n", num_consumers, num_seconds);
+
+        u8        msg[256];
+        u64       seq = 0;
+        const u64 end = now_ns() + (u64)num_seconds * 1000000000ull;
+        while (result >= 0 && now_ns() < end)
+        {
+            const u32 len = msg_len_for(seq);
+            memcpy(msg, &seq, sizeof(seq));
+            for (u32 i = sizeof(seq); i < len; ++i)
+                msg[i] = (u8)(seq + i);
+
+            result = nmmmq::publish(h, msg, len);
+            if ((++seq % 100000) == 0)
+            {
+                printf("\rsoak: published %llu messages...", (unsigned long long)seq);
+                fflush(stdout);
+            }
+        }
+        printf("\n");
+
+        if (result < 0)
+            printf("soak: publish failed at seq %llu (err = %s)\n", (unsigned long long)seq, nmmmq::error_str(result));
+
+        // always send the end marker so that the consumers terminate
+        memcpy(msg, &s_end_marker, sizeof(s_end_marker));
+        nmmmq::publish(h, msg, sizeof(s_end_marker));
+
+        int failures = 0;
+        for (u32 i = 0; i < num_consumers; ++i)
+        {
+            int status = 0;
+            if (waitpid(pids[i], &status, 0) < 0 || !WIFEXITED(status) || WEXITSTATUS(status) != 0)
+                failures++;
+        }
+
+        printf("soak: published %llu messages, %d of %u consumers failed\n", (unsigned long long)seq, failures, num_consumers);
+
+        nmmmq::destroy_handle(h);
+        return (result < 0 || failures > 0) ? -1 : 0;
+    }
+
+}  // namespace ncore
@@ -0,0 +1,134 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_mmmq.h"
+
+#include <unistd.h>
+#include <string.h>
+#include <stdio.h>
+#include <cstdlib>
+#include <time.h>
+
+class malloc_based_alloc_t : public ncore::alloc_t
+{
+public:
+    void* v_allocate(ncore::u32 size, ncore::u32 alignment) { return malloc(size); }
+    void  v_deallocate(void* ptr) { free(ptr); }
+};
+
+namespace ncore
+{
+    static malloc_based_alloc_t s_malloc_based_alloc;
+
+    static u64 now_ns()
+    {
+        struct timespec ts;
+        clock_gettime(CLOCK_MONOTONIC, &ts);
+        return (u64)ts.tv_sec * 1000000000ull + (u64)ts.tv_nsec;
+    }
+
+    // xorshift64, a fixed seed gives the same sequence of message sizes on every run
+    static u64 next_random(u64& state)
+    {
+        state ^= state << 13;
+        state ^= state >> 7;
+        state ^= state << 17;
+        return state;
+    }
+
+    static int usage(const char* app)
+    {
+        printf("Usage: %s [--count <n>] [--rate <msgs/s>] [--min-size <bytes>] [--max-size <bytes>] [--seed <n>] [prefix]\n", app);
+        printf("Publishes --count messages (default 10000) to the channel <prefix>index.mm, <prefix>data.mm,\n");
+        printf("<prefix>control.mm. Sizes are uniform in [min-size, max-size], a rate of 0 publishes as fast as possible.\n");
+        return -1;
+    }
+
+    // Generates synthetic load, every message starts with its sequence number (u64) followed by
+    // bytes derived from it so that a consumer can verify the content.
+    int AppMain(int argc, const char** argv)
+    {
+        u64         count    = 10000;
+        u32         rate     = 1000;
+        u32         min_size = 16;
+        u32         max_size = 256;
+        u64         seed     = 0x9E3779B97F4A7C15ull;
+        const char* prefix   = "";
+        for (int i = 1; i < argc; ++i)
+        {
+            if (strcmp(argv[i], "--count") == 0 && i + 1 < argc)
+                count = (u64)strtoull(argv[++i], nullptr, 10);
+            else if (strcmp(argv[i], "--rate") == 0 && i + 1 < argc)
+                rate = (u32)atoi(argv[++i]);
+            else if (strcmp(argv[i], "--min-size") == 0 && i + 1 < argc)
+                min_size = (u32)atoi(argv[++i]);
+            else if (strcmp(argv[i], "--max-size") == 0 && i + 1 < argc)
+                max_size = (u32)atoi(argv[++i]);
+            else if (strcmp(argv[i], "--seed") == 0 && i + 1 < argc)
+                seed = (u64)strtoull(argv[++i], nullptr, 10);
+            else if (argv[i][0] != '-')
+                prefix = argv[i];
+            else
+                return usage(argv[0]);
+        }
+        if (min_size < sizeof(u64) || max_size < min_size || seed == 0)
+            return usage(argv[0]);
+
+        char index_path[256], data_path[256], control_path[256];
+        char new_sem_name[52], reg_sem_name[52];
+        snprintf(index_path, sizeof(index_path), "%sindex.mm", prefix);
+        snprintf(data_path, sizeof(data_path), "%sdata.mm", prefix);
+        snprintf(control_path, sizeof(control_path), "%scontrol.mm", prefix);
+        snprintf(new_sem_name, sizeof(new_sem_name), "%smmq_new_entries_sem", prefix);
+        snprintf(reg_sem_name, sizeof(reg_sem_name), "%smmq_registry_lock_sem", prefix);
+
+        nmmmq::handle_t* h = nmmmq::create_handle(&s_malloc_based_alloc);
+        nmmmq::config_t  config(1 * cMB, 10 * cMB, 16);
+        i32              result = nmmmq::init_producer(h, config, index_path, data_path, control_path, new_sem_name, reg_sem_name);
+        if (result != 0)
+        {
+            printf("cmmio-synthetic-producer: init_producer failed (err = %s)\n", nmmmq::error_str(result));
+            nmmmq::destroy_handle(h);
+            return -1;
+        }
+
+        u8*       msg    = (u8*)malloc(max_size);
+        const u64 period = (rate > 0) ? (1000000000ull / rate) : 0;
+        const u64 begin  = now_ns();
+        u64       bytes  = 0;
+        u64       seq    = 0;
+        for (; seq < count && result == 0; ++seq)
+        {
+            const u32 len = min_size + (u32)(next_random(seed) % (u64)(max_size - min_size + 1));
+            memcpy(msg, &seq, sizeof(seq));
+            for (u32 i = sizeof(seq); i < len; ++i)
+                msg[i] = (u8)(seq + i);
+
+            // pace against the start time, a late message does not shift the ones after it
+            if (period > 0)
+            {
+                const u64 due = begin + seq * period;
+                const u64 now = now_ns();
+                if (due > now)
+                    usleep((useconds_t)((due - now) / 1000));
+            }
+
+            result = nmmmq::publish(h, msg, len);
+            if (result == 0)
+                bytes += len;
+        }
+        const u64 elapsed = now_ns() - begin;
+
+        free(msg);
+        nmmmq::destroy_handle(h);
+
+        if (result != 0)
+        {
+            printf("cmmio-synthetic-producer: publish failed at message %llu (err = %s)\n", (unsigned long long)(seq - 1), nmmmq::error_str(result));
+            return -1;
+        }
+        const double seconds = (double)elapsed / 1e9;
+        printf("cmmio-synthetic-producer: published %llu messages, %llu bytes in %.2f s (%.0f msgs/s)\n", (unsigned long long)seq, (unsigned long long)bytes, seconds, seconds > 0 ? (double)seq / seconds : 0.0);
+        return 0;
+    }
+
+}  // namespace ncore
@@ -0,0 +1,136 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_mmmq.h"
+
+#include <signal.h>
+#include <unistd.h>
+#include <string.h>
+#include <stdio.h>
+#include <cstdlib>
+
+class malloc_based_alloc_t : public ncore::alloc_t
+{
+public:
+    void* v_allocate(ncore::u32 size, ncore::u32 alignment) { return malloc(size); }
+    void  v_deallocate(void* ptr) { free(ptr); }
+};
+
+namespace ncore
+{
+    static malloc_based_alloc_t s_malloc_based_alloc;
+    static alloc_t*             s_allocator = &s_malloc_based_alloc;
+
+    static volatile sig_atomic_t s_stop = 0;
+    static void                  on_signal(int) { s_stop = 1; }
+
+    // 16 bytes per line: offset, hex and the printable characters
+    static void print_hex(const u8* data, u32 len)
+    {
+        for (u32 offset = 0; offset < len; offset += 16)
+        {
+            printf("  %08x ", offset);
+            for (u32 i = 0; i < 16; ++i)
+            {
+                if (offset + i < len)
+                    printf(" %02x", data[offset + i]);
+                else
+                    printf("   ");
+            }
+            printf("  ");
+            for (u32 i = 0; i < 16 && offset + i < len; ++i)
+            {
+                const u8 c = data[offset + i];
+                putchar((c >= 0x20 && c < 0x7f) ? c : '.');
+            }
+            putchar('\n');
+        }
+    }
+
+    static void print_text(const u8* data, u32 len)
+    {
+        printf("  ");
+        for (u32 i = 0; i < len; ++i)
+        {
+            const u8 c = data[i];
+            if (c == '\n')
+                printf("\\n");
+            else if (c >= 0x20 && c < 0x7f)
+                putchar(c);
+            else if (c != 0 || i + 1 < len)  // a trailing terminator is not shown
+                printf("\\x%02x", c);
+        }
+        putchar('\n');
+    }
+
+    // Attaches as a consumer and prints every new message until interrupted (Ctrl-C), the
+    // consumer slot is released again on exit.
+    int AppMain(int argc, const char** argv)
+    {
+        bool        text       = false;
+        bool        from_start = false;
+        const char* paths[3]   = {"index.mm", "data.mm", "control.mm"};
+        int         num_paths  = 0;
+        for (int i = 1; i < argc; ++i)
+        {
+            if (strcmp(argv[i], "--text") == 0)
+                text = true;
+            else if (strcmp(argv[i], "--from-start") == 0)
+                from_start = true;
+            else if (argv[i][0] != '-' && num_paths < 3)
+                paths[num_paths++] = argv[i];
+            else
+            {
+                printf("Usage: %s [--text] [--from-start] [index_path] [data_path] [control_path]\n", argv[0]);
+                return -1;
+            }
+        }
+
+        nmmmq::handle_t* h      = nmmmq::create_handle(s_allocator);
+        nmmmq::info_t    info;
+        i32              result = nmmmq::attach_consumer(h, paths[0], paths[1], paths[2]);
+        if (result == 0)
+            result = nmmmq::inspect(h, info);
+
+        // a name per process, so that a second tail on the same channel gets its own cursor
+        char name[44];
+        snprintf(name, sizeof(name), "cmmio-tail-%d", (int)getpid());
+
+        u64 seq  = from_start ? 0 : info.next_seq;
+        i32 slot = -1;
+        if (result == 0)
+            result = nmmmq::register_consumer(h, name, (u32)seq, slot);
+        if (result != 0)
+        {
+            printf("cmmio-tail: %s\n", nmmmq::error_str(result));
+            nmmmq::destroy_handle(h);
+            return -1;
+        }
+
+        signal(SIGINT, on_signal);
+        signal(SIGTERM, on_signal);
+
+        while (!s_stop)
+        {
+            const u8* msg_data;
+            u32       msg_len;
+            if (!nmmmq::consumer_drain(h, slot, msg_data, msg_len))
+            {
+                nmmmq::consumer_heartbeat(h, slot);
+                nmmmq::wait_for_new_timeout(h, 100 * 1000);
+                continue;
+            }
+
+            printf("[%llu] %u bytes\n", (unsigned long long)seq++, msg_len);
+            if (text)
+                print_text(msg_data, msg_len);
+            else
+                print_hex(msg_data, msg_len);
+            fflush(stdout);
+        }
+
+        nmmmq::release_consumer(h, slot);
+        nmmmq::destroy_handle(h);
+        return 0;
+    }
+
+}  // namespace ncore
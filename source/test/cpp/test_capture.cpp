@@ -0,0 +1,138 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_capture.h"
+
+#include "cunittest/cunittest.h"
+
+#include <stdio.h>
+#include <string.h>
+#include <unistd.h>
+
+using namespace ncore;
+
+static const char* s_capture_path = "test_capture.bin";
+
+// Writes the first @bytes bytes of @data as the whole capture file.
+static bool write_raw(const void* data, size_t bytes)
+{
+    FILE* f = fopen(s_capture_path, "wb");
+    if (f == nullptr)
+        return false;
+    const bool ok = fwrite(data, 1, bytes, f) == bytes;
+    fclose(f);
+    return ok;
+}
+
+UNITTEST_SUITE_BEGIN(capture)
+{
+    UNITTEST_FIXTURE(format)
+    {
+        UNITTEST_FIXTURE_SETUP() {}
+        UNITTEST_FIXTURE_TEARDOWN() { unlink(s_capture_path); }
+
+        UNITTEST_ALLOCATOR;
+
+        UNITTEST_TEST(round_trip)
+        {
+            ncapture::writer_t* w = nullptr;
+            CHECK_EQUAL(0, ncapture::open_writer(Allocator, s_capture_path, w));
+            CHECK_NOT_NULL(w);
+            CHECK_EQUAL(0, ncapture::write_frame(w, 0, "hello", 5));
+            CHECK_EQUAL(0, ncapture::write_frame(w, 1500, nullptr, 0));
+            CHECK_EQUAL(0, ncapture::write_frame(w, 3000, "a longer payload", 16));
+            ncapture::close_writer(w);
+            CHECK_NULL(w);
+
+            ncapture::reader_t* r = nullptr;
+            CHECK_EQUAL(0, ncapture::open_reader(Allocator, s_capture_path, r));
+            CHECK_NOT_NULL(r);
+
+            ncapture::frame_t frame;
+            const u8*         payload = nullptr;
+            CHECK_EQUAL((i32)ncapture::CAPTURE_OK, ncapture::read_frame(r, frame, payload));
+            CHECK_EQUAL(0, (i32)frame.m_time_ns);
+            CHECK_EQUAL(5, (i32)frame.m_len);
+            CHECK_EQUAL(0, memcmp(payload, "hello", 5));
+
+            CHECK_EQUAL((i32)ncapture::CAPTURE_OK, ncapture::read_frame(r, frame, payload));
+            CHECK_EQUAL(1500, (i32)frame.m_time_ns);
+            CHECK_EQUAL(0, (i32)frame.m_len);
+
+            CHECK_EQUAL((i32)ncapture::CAPTURE_OK, ncapture::read_frame(r, frame, payload));
+            CHECK_EQUAL(3000, (i32)frame.m_time_ns);
+            CHECK_EQUAL(16, (i32)frame.m_len);
+            CHECK_EQUAL(0, memcmp(payload, "a longer payload", 16));
+
+            CHECK_EQUAL((i32)ncapture::CAPTURE_END, ncapture::read_frame(r, frame, payload));
+            ncapture::close_reader(r);
+            CHECK_NULL(r);
+        }
+
+        UNITTEST_TEST(missing_file)
+        {
+            ncapture::reader_t* r = nullptr;
+            CHECK_EQUAL((i32)ncapture::CAPTURE_ERR_OPEN, ncapture::open_reader(Allocator, "this_capture_does_not_exist.bin", r));
+            CHECK_NULL(r);
+        }
+
+        UNITTEST_TEST(not_a_capture)
+        {
+            const char text[] = "this is not a capture file";
+            CHECK_TRUE(write_raw(text, sizeof(text)));
+
+            ncapture::reader_t* r = nullptr;
+            CHECK_EQUAL((i32)ncapture::CAPTURE_ERR_FORMAT, ncapture::open_reader(Allocator, s_capture_path, r));
+            CHECK_NULL(r);
+        }
+
+        UNITTEST_TEST(other_version)
+        {
+            const ncapture::header_t header = {MMQ_CAPTURE_MAGIC, MMQ_CAPTURE_VERSION + 1, 0};
+            CHECK_TRUE(write_raw(&header, sizeof(header)));
+
+            ncapture::reader_t* r = nullptr;
+            CHECK_EQUAL((i32)ncapture::CAPTURE_ERR_VERSION, ncapture::open_reader(Allocator, s_capture_path, r));
+            CHECK_NULL(r);
+        }
+
+        UNITTEST_TEST(truncated_frame)
+        {
+            // a header and a frame that announces 8 bytes of payload but only has 3
+            u8                       file[sizeof(ncapture::header_t) + sizeof(ncapture::frame_t) + 3];
+            const ncapture::header_t header = {MMQ_CAPTURE_MAGIC, MMQ_CAPTURE_VERSION, 0};
+            const ncapture::frame_t  frame  = {0, 8, 0};
+            memcpy(file, &header, sizeof(header));
+            memcpy(file + sizeof(header), &frame, sizeof(frame));
+            memcpy(file + sizeof(header) + sizeof(frame), "abc", 3);
+            CHECK_TRUE(write_raw(file, sizeof(file)));
+
+            ncapture::reader_t* r = nullptr;
+            CHECK_EQUAL(0, ncapture::open_reader(Allocator, s_capture_path, r));
+
+            ncapture::frame_t read;
+            const u8*         payload = nullptr;
+            CHECK_EQUAL((i32)ncapture::CAPTURE_ERR_TRUNCATED, ncapture::read_frame(r, read, payload));
+            ncapture::close_reader(r);
+        }
+
+        UNITTEST_TEST(huge_frame)
+        {
+            // a header and a frame that announces 4 GiB of payload
+            u8                       file[sizeof(ncapture::header_t) + sizeof(ncapture::frame_t)];
+            const ncapture::header_t header = {MMQ_CAPTURE_MAGIC, MMQ_CAPTURE_VERSION, 0};
+            const ncapture::frame_t  frame  = {0, 0xFFFFFFFFu, 0};
+            memcpy(file, &header, sizeof(header));
+            memcpy(file + sizeof(header), &frame, sizeof(frame));
+            CHECK_TRUE(write_raw(file, sizeof(file)));
+
+            ncapture::reader_t* r = nullptr;
+            CHECK_EQUAL(0, ncapture::open_reader(Allocator, s_capture_path, r));
+
+            ncapture::frame_t read;
+            const u8*         payload = nullptr;
+            CHECK_EQUAL((i32)ncapture::CAPTURE_ERR_FRAME, ncapture::read_frame(r, read, payload));
+            ncapture::close_reader(r);
+        }
+    }
+}
+UNITTEST_SUITE_END
@@ -0,0 +1,458 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_mmmq.h"
+
+#include "cunittest/cunittest.h"
+
+#include <errno.h>
+#include <fcntl.h>
+#include <string.h>
+#include <unistd.h>
+#include <semaphore.h>
+#include <sys/stat.h>
+
+using namespace ncore;
+
+UNITTEST_SUITE_BEGIN(mmmq)
+{
+    UNITTEST_FIXTURE(producer)
+    {
+        UNITTEST_FIXTURE_SETUP() {}
+
+        // the named semaphores outlive the handles, like cmmio-ctl destroy the tests remove them
+        UNITTEST_FIXTURE_TEARDOWN()
+        {
+            sem_unlink("test_mmmq_new_sem");
+            sem_unlink("test_mmmq_reg_sem");
+        }
+
+        UNITTEST_ALLOCATOR;
+
+        UNITTEST_TEST(heartbeat)
+        {
+            const char* index_path   = "test_mmmq_index.mm";
+            const char* data_path    = "test_mmmq_data.mm";
+            const char* control_path = "test_mmmq_control.mm";
+
+            nmmmq::handle_t* p = nmmmq::create_handle(Allocator);
+            nmmmq::handle_t* c = nmmmq::create_handle(Allocator);
+            nmmmq::config_t  config(64 * 1024, 64 * 1024, 4);
+            CHECK_EQUAL(0, nmmmq::init_producer(p, config, index_path, data_path, control_path, "test_mmmq_new_sem", "test_mmmq_reg_sem"));
+            CHECK_EQUAL(0, nmmmq::attach_consumer(c, index_path, data_path, control_path));
+
+            // registering counts as the first heartbeat
+            const u64 before = nmmmq::clock_ns();
+            i32       slot   = -1;
+            CHECK_EQUAL(0, nmmmq::register_consumer(c, "heartbeat", 0, slot));
+
+            nmmmq::consumer_info_t registered;
+            CHECK_EQUAL(0, nmmmq::inspect_consumer(c, slot, registered));
+            CHECK_TRUE(registered.last_update_ns >= before);
+
+            usleep(1000);
+            CHECK_EQUAL(0, nmmmq::consumer_heartbeat(c, slot));
+
+            nmmmq::consumer_info_t beat;
+            CHECK_EQUAL(0, nmmmq::inspect_consumer(c, slot, beat));
+            CHECK_TRUE(beat.last_update_ns > registered.last_update_ns);
+            CHECK_TRUE(beat.last_update_ns <= nmmmq::clock_ns());
+
+            // only a consumer handle has a slot to update
+            CHECK_TRUE(nmmmq::consumer_heartbeat(p, slot) != 0);
+            CHECK_TRUE(nmmmq::consumer_heartbeat(c, 4) != 0);
+
+            nmmmq::destroy_handle(c);
+            nmmmq::destroy_handle(p);
+
+            unlink(index_path);
+            unlink(data_path);
+            unlink(control_path);
+        }
+
+        UNITTEST_TEST(reserve)
+        {
+            const char* index_path   = "test_mmmq_index.mm";
+            const char* data_path    = "test_mmmq_data.mm";
+            const char* control_path = "test_mmmq_control.mm";
+
+            nmmmq::handle_t* p = nmmmq::create_handle(Allocator);
+            nmmmq::config_t  config(64 * 1024, 64 * 1024, 4);
+            CHECK_EQUAL(0, nmmmq::init_producer(p, config, index_path, data_path, control_path, "test_mmmq_new_sem", "test_mmmq_reg_sem"));
+            CHECK_EQUAL(0, nmmmq::publish(p, "hello", 6));
+
+            nmmmq::info_t before;
+            CHECK_EQUAL(0, nmmmq::inspect(p, before));
+
+            // growing keeps the content, a smaller size is ignored
+            CHECK_EQUAL(0, nmmmq::reserve(p, 128 * 1024, 256 * 1024));
+            CHECK_EQUAL(0, nmmmq::reserve(p, 1024, 1024));
+
+            nmmmq::info_t after;
+            CHECK_EQUAL(0, nmmmq::inspect(p, after));
+            CHECK_EQUAL(before.next_seq, after.next_seq);
+            CHECK_EQUAL(before.data_write_pos, after.data_write_pos);
+            CHECK_TRUE(after.index_capacity > before.index_capacity);
+            CHECK_EQUAL((u64)(256 * 1024 - 32), after.data_capacity);
+
+            nmmmq::destroy_handle(p);
+
+            unlink(index_path);
+            unlink(data_path);
+            unlink(control_path);
+        }
+
+        UNITTEST_TEST(index_grow)
+        {
+            const char* index_path   = "test_mmmq_index.mm";
+            const char* data_path    = "test_mmmq_data.mm";
+            const char* control_path = "test_mmmq_control.mm";
+
+            nmmmq::handle_t* p = nmmmq::create_handle(Allocator);
+            nmmmq::config_t  config(4 * 1024, 64 * 1024, 4);
+            CHECK_EQUAL(0, nmmmq::init_producer(p, config, index_path, data_path, control_path, "test_mmmq_new_sem", "test_mmmq_reg_sem"));
+
+            nmmmq::info_t before;
+            CHECK_EQUAL(0, nmmmq::inspect(p, before));
+
+            // publishing past the initial capacity grows the index
+            const u64 count = before.index_capacity + 16;
+            for (u64 i = 0; i < count; ++i)
+                CHECK_EQUAL(0, nmmmq::publish(p, &i, sizeof(i)));
+
+            nmmmq::info_t after;
+            CHECK_EQUAL(0, nmmmq::inspect(p, after));
+            CHECK_EQUAL(count, after.next_seq);
+            CHECK_TRUE(after.index_capacity >= count);
+
+            // a consumer attached after the growth reads every message
+            nmmmq::handle_t* c = nmmmq::create_handle(Allocator);
+            CHECK_EQUAL(0, nmmmq::attach_consumer(c, index_path, data_path, control_path));
+            i32 slot = -1;
+            CHECK_EQUAL(0, nmmmq::register_consumer(c, "index_grow", 0, slot));
+
+            u8 const* msg_data = nullptr;
+            u32       msg_len  = 0;
+            u64       read     = 0;
+            while (nmmmq::consumer_drain(c, slot, msg_data, msg_len))
+            {
+                CHECK_EQUAL((u32)sizeof(u64), msg_len);
+                u64 value = 0;
+                memcpy(&value, msg_data, sizeof(value));
+                CHECK_EQUAL(read, value);
+                read++;
+            }
+            CHECK_EQUAL(count, read);
+
+            nmmmq::destroy_handle(c);
+            nmmmq::destroy_handle(p);
+
+            unlink(index_path);
+            unlink(data_path);
+            unlink(control_path);
+        }
+
+        UNITTEST_TEST(large_message)
+        {
+            const char* index_path   = "test_mmmq_index.mm";
+            const char* data_path    = "test_mmmq_data.mm";
+            const char* control_path = "test_mmmq_control.mm";
+
+            nmmmq::handle_t* p = nmmmq::create_handle(Allocator);
+            nmmmq::config_t  config(64 * 1024, 64 * 1024, 4);
+            CHECK_EQUAL(0, nmmmq::init_producer(p, config, index_path, data_path, control_path, "test_mmmq_new_sem", "test_mmmq_reg_sem"));
+
+            // a message larger than the whole data.mm, growing by 10% is not enough for it
+            const u32 len = 256 * 1024;
+            u8*       msg = (u8*)Allocator->allocate(len);
+            for (u32 i = 0; i < len; ++i)
+                msg[i] = (u8)i;
+            CHECK_EQUAL(0, nmmmq::publish(p, "small", 6));
+            CHECK_EQUAL(0, nmmmq::publish(p, msg, len));
+
+            nmmmq::info_t info;
+            CHECK_EQUAL(0, nmmmq::inspect(p, info));
+            CHECK_TRUE(info.data_capacity >= info.data_write_pos);
+
+            nmmmq::handle_t* c = nmmmq::create_handle(Allocator);
+            CHECK_EQUAL(0, nmmmq::attach_consumer(c, index_path, data_path, control_path));
+            i32 slot = -1;
+            CHECK_EQUAL(0, nmmmq::register_consumer(c, "large_message", 0, slot));
+
+            u8 const* msg_data = nullptr;
+            u32       msg_len  = 0;
+            CHECK_TRUE(nmmmq::consumer_drain(c, slot, msg_data, msg_len));
+            CHECK_TRUE(nmmmq::consumer_drain(c, slot, msg_data, msg_len));
+            CHECK_EQUAL(len, msg_len);
+            CHECK_EQUAL(0, memcmp(msg_data, msg, len));
+
+            Allocator->deallocate(msg);
+            nmmmq::destroy_handle(c);
+            nmmmq::destroy_handle(p);
+
+            unlink(index_path);
+            unlink(data_path);
+            unlink(control_path);
+        }
+
+        UNITTEST_TEST(large_offset)
+        {
+            const char* index_path   = "test_mmmq_index.mm";
+            const char* data_path    = "test_mmmq_data.mm";
+            const char* control_path = "test_mmmq_control.mm";
+
+            // data.mm grows sparse past 32 GiB, only the pages that are written take up space
+            const u64 far_pos = (1ull << 35) + 64;
+
+            nmmmq::handle_t* p = nmmmq::create_handle(Allocator);
+            nmmmq::config_t  config(64 * 1024, 64 * 1024, 4);
+            CHECK_EQUAL(0, nmmmq::init_producer(p, config, index_path, data_path, control_path, "test_mmmq_new_sem", "test_mmmq_reg_sem"));
+            CHECK_EQUAL(0, nmmmq::reserve(p, 64 * 1024, (uint_t)(far_pos + 64 * 1024)));
+            nmmmq::destroy_handle(p);
+
+            // move the write position of the data.mm header (u64 magic, u32 version, u32 align,
+            // u64 write_pos) to the far end, the producer continues from there when it reopens
+            const int fd = open(data_path, O_RDWR);
+            CHECK_TRUE(fd >= 0);
+            CHECK_EQUAL((ssize_t)sizeof(far_pos), pwrite(fd, &far_pos, sizeof(far_pos), 16));
+            close(fd);
+
+            p = nmmmq::create_handle(Allocator);
+            CHECK_EQUAL(0, nmmmq::init_producer(p, config, index_path, data_path, control_path, "test_mmmq_new_sem", "test_mmmq_reg_sem"));
+            CHECK_EQUAL(0, nmmmq::publish(p, "far", 4));
+
+            nmmmq::info_t info;
+            CHECK_EQUAL(0, nmmmq::inspect(p, info));
+            CHECK_EQUAL(far_pos + 8, info.data_write_pos);
+
+            // the offset of the index entry does not wrap, the consumer reads the message back
+            nmmmq::handle_t* c = nmmmq::create_handle(Allocator);
+            CHECK_EQUAL(0, nmmmq::attach_consumer(c, index_path, data_path, control_path));
+            i32 slot = -1;
+            CHECK_EQUAL(0, nmmmq::register_consumer(c, "large_offset", 0, slot));
+
+            u8 const* msg_data = nullptr;
+            u32       msg_len  = 0;
+            CHECK_TRUE(nmmmq::consumer_drain(c, slot, msg_data, msg_len));
+            CHECK_EQUAL((u32)4, msg_len);
+            CHECK_EQUAL(0, strcmp((const char*)msg_data, "far"));
+
+            nmmmq::destroy_handle(c);
+            nmmmq::destroy_handle(p);
+
+            unlink(index_path);
+            unlink(data_path);
+            unlink(control_path);
+        }
+
+        UNITTEST_TEST(batch)
+        {
+            const char* index_path   = "test_mmmq_index.mm";
+            const char* data_path    = "test_mmmq_data.mm";
+            const char* control_path = "test_mmmq_control.mm";
+
+            nmmmq::handle_t* p = nmmmq::create_handle(Allocator);
+            nmmmq::handle_t* c = nmmmq::create_handle(Allocator);
+            nmmmq::config_t  config(64 * 1024, 64 * 1024, 4);
+            CHECK_EQUAL(0, nmmmq::init_producer(p, config, index_path, data_path, control_path, "test_mmmq_new_sem", "test_mmmq_reg_sem"));
+            CHECK_EQUAL(0, nmmmq::attach_consumer(c, index_path, data_path, control_path));
+
+            i32 slot = -1;
+            CHECK_EQUAL(0, nmmmq::register_consumer(c, "batch", 0, slot));
+
+            const char* msgs[] = {"one", "two", "three"};
+            const u32   lens[] = {4, 4, 6};
+            CHECK_EQUAL(0, nmmmq::publish_batch(p, (const void* const*)msgs, lens, 3));
+            CHECK_EQUAL(0, nmmmq::publish(p, "four", 5));
+
+            nmmmq::info_t info;
+            CHECK_EQUAL(0, nmmmq::inspect(p, info));
+            CHECK_EQUAL((u64)4, info.next_seq);
+
+            // a batch stops at max_count, the next one continues after it
+            u8 const* msg_data[4];
+            u32       msg_len[4];
+            CHECK_EQUAL((u32)2, nmmmq::consumer_drain_batch(c, slot, msg_data, msg_len, 2));
+            CHECK_EQUAL(0, strcmp((const char*)msg_data[0], "one"));
+            CHECK_EQUAL(0, strcmp((const char*)msg_data[1], "two"));
+            CHECK_EQUAL((u32)2, nmmmq::consumer_drain_batch(c, slot, msg_data, msg_len, 4));
+            CHECK_EQUAL((u32)6, msg_len[0]);
+            CHECK_EQUAL(0, strcmp((const char*)msg_data[0], "three"));
+            CHECK_EQUAL(0, strcmp((const char*)msg_data[1], "four"));
+            CHECK_EQUAL((u32)0, nmmmq::consumer_drain_batch(c, slot, msg_data, msg_len, 4));
+
+            nmmmq::consumer_info_t ci;
+            CHECK_EQUAL(0, nmmmq::inspect_consumer(c, slot, ci));
+            CHECK_EQUAL((u64)4, ci.last_seq);
+
+            nmmmq::destroy_handle(c);
+            nmmmq::destroy_handle(p);
+
+            unlink(index_path);
+            unlink(data_path);
+            unlink(control_path);
+        }
+
+        UNITTEST_TEST(fan_out)
+        {
+            const char* index_path   = "test_mmmq_index.mm";
+            const char* data_path    = "test_mmmq_data.mm";
+            const char* control_path = "test_mmmq_control.mm";
+
+            nmmmq::handle_t* p  = nmmmq::create_handle(Allocator);
+            nmmmq::handle_t* c1 = nmmmq::create_handle(Allocator);
+            nmmmq::handle_t* c2 = nmmmq::create_handle(Allocator);
+            nmmmq::config_t  config(64 * 1024, 64 * 1024, 2);
+            CHECK_EQUAL(0, nmmmq::init_producer(p, config, index_path, data_path, control_path, "test_mmmq_new_sem", "test_mmmq_reg_sem"));
+            CHECK_EQUAL(0, nmmmq::attach_consumer(c1, index_path, data_path, control_path));
+            CHECK_EQUAL(0, nmmmq::attach_consumer(c2, index_path, data_path, control_path));
+
+            // every consumer has its own cursor, the second one starts at message 1
+            i32 slot1 = -1, slot2 = -1, slot3 = -1;
+            CHECK_EQUAL(0, nmmmq::register_consumer(c1, "first", 0, slot1));
+            CHECK_EQUAL(0, nmmmq::register_consumer(c2, "second", 1, slot2));
+            CHECK_TRUE(slot1 != slot2);
+            CHECK_TRUE(nmmmq::register_consumer(c2, "third", 0, slot3) != 0);  // max_consumers is 2
+
+            CHECK_EQUAL(0, nmmmq::publish(p, "a", 2));
+            CHECK_EQUAL(0, nmmmq::publish(p, "b", 2));
+            CHECK_EQUAL(0, nmmmq::publish(p, "c", 2));
+
+            // draining one consumer does not move the other
+            u8 const* msg_data = nullptr;
+            u32       msg_len  = 0;
+            u32       count    = 0;
+            while (nmmmq::consumer_drain(c1, slot1, msg_data, msg_len))
+                count++;
+            CHECK_EQUAL((u32)3, count);
+            CHECK_TRUE(nmmmq::consumer_drain(c2, slot2, msg_data, msg_len));
+            CHECK_EQUAL(0, strcmp((const char*)msg_data, "b"));
+
+            nmmmq::consumer_info_t ci;
+            CHECK_EQUAL(0, nmmmq::inspect_consumer(c1, slot1, ci));
+            CHECK_EQUAL((u64)3, ci.last_seq);
+            CHECK_EQUAL(0, nmmmq::inspect_consumer(c2, slot2, ci));
+            CHECK_EQUAL((u64)2, ci.last_seq);
+
+            // registering under an existing name returns that slot with its cursor
+            i32 again = -1;
+            CHECK_EQUAL(0, nmmmq::register_consumer(c1, "second", 0, again));
+            CHECK_EQUAL(slot2, again);
+            CHECK_TRUE(nmmmq::consumer_drain(c1, again, msg_data, msg_len));
+            CHECK_EQUAL(0, strcmp((const char*)msg_data, "c"));
+
+            // a released slot can be taken by a new consumer
+            CHECK_EQUAL(0, nmmmq::release_consumer(c1, slot1));
+            CHECK_EQUAL(0, nmmmq::register_consumer(c2, "third", 0, slot3));
+            CHECK_EQUAL(slot1, slot3);
+
+            nmmmq::destroy_handle(c2);
+            nmmmq::destroy_handle(c1);
+            nmmmq::destroy_handle(p);
+
+            unlink(index_path);
+            unlink(data_path);
+            unlink(control_path);
+        }
+
+        UNITTEST_TEST(wait_cancel)
+        {
+            const char* index_path   = "test_mmmq_index.mm";
+            const char* data_path    = "test_mmmq_data.mm";
+            const char* control_path = "test_mmmq_control.mm";
+
+            // a semaphore of its own, the other tests leave posts behind that nobody waited for
+            sem_unlink("test_mmmq_wait_new_sem");
+            sem_unlink("test_mmmq_wait_reg_sem");
+
+            nmmmq::handle_t* p = nmmmq::create_handle(Allocator);
+            nmmmq::handle_t* c = nmmmq::create_handle(Allocator);
+            nmmmq::config_t  config(64 * 1024, 64 * 1024, 4);
+            CHECK_EQUAL(0, nmmmq::init_producer(p, config, index_path, data_path, control_path, "test_mmmq_wait_new_sem", "test_mmmq_wait_reg_sem"));
+            CHECK_EQUAL(0, nmmmq::attach_consumer(c, index_path, data_path, control_path));
+
+            // nothing published, the wait takes at least the timeout
+            u64 begin = nmmmq::clock_ns();
+            CHECK_FALSE(nmmmq::wait_for_new_timeout(c, 2000));
+            CHECK_EQUAL(ETIMEDOUT, errno);
+            CHECK_TRUE(nmmmq::clock_ns() - begin >= 2000 * 1000);
+
+            CHECK_EQUAL(0, nmmmq::publish(p, "a", 2));
+            CHECK_TRUE(nmmmq::wait_for_new_timeout(c, 2000));
+
+            // a cancelled wait returns long before its timeout of 10 s
+            volatile i32 cancel = 1;
+            begin               = nmmmq::clock_ns();
+            CHECK_FALSE(nmmmq::wait_for_new_cancel(c, 10 * 1000 * 1000, &cancel));
+            CHECK_EQUAL(ECANCELED, errno);
+            CHECK_TRUE(nmmmq::clock_ns() - begin < 1000ull * 1000 * 1000);
+
+            // a message that is already there is reported even when cancelled
+            CHECK_EQUAL(0, nmmmq::publish(p, "b", 2));
+            CHECK_TRUE(nmmmq::wait_for_new_cancel(c, 10 * 1000 * 1000, &cancel));
+            cancel = 0;
+            CHECK_EQUAL(0, nmmmq::publish(p, "c", 2));
+            CHECK_TRUE(nmmmq::wait_for_new_cancel(c, 2000, &cancel));
+
+            // a timeout of 0 polls once, it reports a message, a cancel or nothing
+            CHECK_FALSE(nmmmq::wait_for_new_cancel(c, 0, &cancel));
+            CHECK_EQUAL(ETIMEDOUT, errno);
+            CHECK_EQUAL(0, nmmmq::publish(p, "d", 2));
+            CHECK_TRUE(nmmmq::wait_for_new_cancel(c, 0, &cancel));
+            cancel = 1;
+            CHECK_FALSE(nmmmq::wait_for_new_cancel(c, 0, &cancel));
+            CHECK_EQUAL(ECANCELED, errno);
+
+            nmmmq::destroy_handle(c);
+            nmmmq::destroy_handle(p);
+
+            unlink(index_path);
+            unlink(data_path);
+            unlink(control_path);
+            sem_unlink("test_mmmq_wait_new_sem");
+            sem_unlink("test_mmmq_wait_reg_sem");
+        }
+
+        UNITTEST_TEST(mode)
+        {
+            const char* index_path   = "test_mmmq_mode_index.mm";
+            const char* data_path    = "test_mmmq_mode_data.mm";
+            const char* control_path = "test_mmmq_mode_control.mm";
+
+            const mode_t umask_before = umask(022);
+
+            nmmmq::handle_t* p = nmmmq::create_handle(Allocator);
+            nmmmq::config_t  config(64 * 1024, 64 * 1024, 4);
+            config.mode = 0600;
+            CHECK_EQUAL(0, nmmmq::init_producer(p, config, index_path, data_path, control_path, "test_mmmq_mode_new_sem", "test_mmmq_mode_reg_sem"));
+
+            struct stat st;
+            CHECK_EQUAL(0, stat(index_path, &st));
+            CHECK_EQUAL(0600, (i32)(st.st_mode & 0777));
+            CHECK_EQUAL(0, stat(data_path, &st));
+            CHECK_EQUAL(0600, (i32)(st.st_mode & 0777));
+            CHECK_EQUAL(0, stat(control_path, &st));
+            CHECK_EQUAL(0600, (i32)(st.st_mode & 0777));
+
+            // a process without access to the files is refused by attach, root is never refused
+            if (geteuid() != 0)
+            {
+                CHECK_EQUAL(0, chmod(index_path, 0200));
+                nmmmq::handle_t* c      = nmmmq::create_handle(Allocator);
+                const i32        result = nmmmq::attach_consumer(c, index_path, data_path, control_path);
+                CHECK_TRUE(result < 0);
+                CHECK_NOT_NULL(strstr(nmmmq::error_str(result), "index.mm"));
+                nmmmq::destroy_handle(c);
+            }
+
+            nmmmq::destroy_handle(p);
+            umask(umask_before);
+
+            unlink(index_path);
+            unlink(data_path);
+            unlink(control_path);
+            sem_unlink("test_mmmq_mode_new_sem");
+            sem_unlink("test_mmmq_mode_reg_sem");
+        }
+    }
+}
+UNITTEST_SUITE_END
@@ -0,0 +1,288 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_mmmq.h"
+
+#include "cunittest/cunittest.h"
+
+#include <stdio.h>
+#include <string.h>
+#include <fcntl.h>
+#include <unistd.h>
+#include <semaphore.h>
+
+using namespace ncore;
+
+// The fixtures in data/corrupt are written by testdata-gen, they are the files left behind by a
+// producer that died, each scenario is a set of <name>_index.bin, <name>_data.bin and <name>_control.bin.
+// The channel holds 3 messages of 100 bytes ('a', 'b' and 'c') and consumer "crashed" was registered
+// in slot 0 and read 2 of them. The channels in data/layout hold the same messages, they are written
+// by this version of the library (v<CMMIO_ABI_VERSION>) or carry a newer version in their headers.
+
+static const char* s_new_sem_name = "mmq_fixture_new_entries_sem";
+static const char* s_reg_sem_name = "mmq_fixture_registry_lock_sem";
+
+static const char* s_index_path   = "test_recovery_index.mm";
+static const char* s_data_path    = "test_recovery_data.mm";
+static const char* s_control_path = "test_recovery_control.mm";
+
+static bool copy_file(const char* src, const char* dst)
+{
+    FILE* in = fopen(src, "rb");
+    if (in == nullptr)
+        return false;
+    FILE* out = fopen(dst, "wb");
+    if (out == nullptr)
+    {
+        fclose(in);
+        return false;
+    }
+    int c;
+    while ((c = fgetc(in)) != EOF)
+        fputc(c, out);
+    fclose(out);
+    fclose(in);
+    return true;
+}
+
+// Consumers write their cursor to control.mm, so they work on a copy of the scenario (e.g.
+// "corrupt/torn_header") and need the semaphores of the producer that is gone.
+static bool setup_channel(const char* scenario)
+{
+    char src[128];
+    snprintf(src, sizeof(src), "data/%s_index.bin", scenario);
+    bool ok = copy_file(src, s_index_path);
+    snprintf(src, sizeof(src), "data/%s_data.bin", scenario);
+    ok = copy_file(src, s_data_path) && ok;
+    snprintf(src, sizeof(src), "data/%s_control.bin", scenario);
+    ok = copy_file(src, s_control_path) && ok;
+
+    sem_t* new_sem = sem_open(s_new_sem_name, O_CREAT, 0666, 0);
+    sem_t* reg_sem = sem_open(s_reg_sem_name, O_CREAT, 0666, 1);
+    ok             = ok && new_sem != SEM_FAILED && reg_sem != SEM_FAILED;
+    if (new_sem != SEM_FAILED)
+        sem_close(new_sem);
+    if (reg_sem != SEM_FAILED)
+        sem_close(reg_sem);
+    return ok;
+}
+
+static void teardown_channel()
+{
+    unlink(s_index_path);
+    unlink(s_data_path);
+    unlink(s_control_path);
+    sem_unlink(s_new_sem_name);
+    sem_unlink(s_reg_sem_name);
+}
+
+UNITTEST_SUITE_BEGIN(mmmq_recovery)
+{
+    UNITTEST_FIXTURE(corrupt)
+    {
+        UNITTEST_FIXTURE_SETUP() {}
+        UNITTEST_FIXTURE_TEARDOWN() {}
+
+        UNITTEST_ALLOCATOR;
+
+        UNITTEST_TEST(torn_header_inspector)
+        {
+            nmmmq::handle_t* h      = nmmmq::create_handle(Allocator);
+            const i32        result = nmmmq::attach_inspector(h, "data/corrupt/torn_header_index.bin", "data/corrupt/torn_header_data.bin", "data/corrupt/torn_header_control.bin");
+            CHECK_TRUE(result < 0);
+            CHECK_NOT_NULL(strstr(nmmmq::error_str(result), "index.mm sanity"));
+            nmmmq::destroy_handle(h);
+        }
+
+        UNITTEST_TEST(torn_header_consumer)
+        {
+            CHECK_TRUE(setup_channel("corrupt/torn_header"));
+
+            nmmmq::handle_t* h      = nmmmq::create_handle(Allocator);
+            const i32        result = nmmmq::attach_consumer(h, s_index_path, s_data_path, s_control_path);
+            CHECK_TRUE(result < 0);
+            CHECK_NOT_NULL(strstr(nmmmq::error_str(result), "index.mm sanity"));
+            nmmmq::destroy_handle(h);
+
+            teardown_channel();
+        }
+
+        UNITTEST_TEST(stale_consumer_slot)
+        {
+            nmmmq::handle_t* h = nmmmq::create_handle(Allocator);
+            CHECK_EQUAL(0, nmmmq::attach_inspector(h, "data/corrupt/stale_consumer_index.bin", "data/corrupt/stale_consumer_data.bin", "data/corrupt/stale_consumer_control.bin"));
+
+            nmmmq::info_t info;
+            CHECK_EQUAL(0, nmmmq::inspect(h, info));
+            CHECK_EQUAL((u64)3, info.next_seq);
+            CHECK_EQUAL((u16)4, info.max_consumers);
+
+            nmmmq::consumer_info_t slot;
+            CHECK_EQUAL(0, nmmmq::inspect_consumer(h, 0, slot));
+            CHECK_TRUE(slot.active);
+            CHECK_EQUAL(0, strcmp("crashed", slot.name));
+            CHECK_EQUAL((u64)2, slot.last_seq);
+
+            nmmmq::destroy_handle(h);
+        }
+
+        UNITTEST_TEST(stale_consumer_resumes)
+        {
+            CHECK_TRUE(setup_channel("corrupt/stale_consumer"));
+
+            nmmmq::handle_t* h = nmmmq::create_handle(Allocator);
+            CHECK_EQUAL(0, nmmmq::attach_consumer(h, s_index_path, s_data_path, s_control_path));
+
+            // registering under the same name takes over the slot and its cursor
+            i32 slot = -1;
+            CHECK_EQUAL(0, nmmmq::register_consumer(h, "crashed", 0, slot));
+            CHECK_EQUAL(0, slot);
+
+            const u8* msg_data = nullptr;
+            u32       msg_len  = 0;
+            CHECK_TRUE(nmmmq::consumer_drain(h, slot, msg_data, msg_len));
+            CHECK_EQUAL((u32)100, msg_len);
+            CHECK_EQUAL('c', (char)msg_data[0]);
+            CHECK_FALSE(nmmmq::consumer_drain(h, slot, msg_data, msg_len));
+
+            nmmmq::destroy_handle(h);
+            teardown_channel();
+        }
+
+        UNITTEST_TEST(stale_consumer_released)
+        {
+            CHECK_TRUE(setup_channel("corrupt/stale_consumer"));
+
+            nmmmq::handle_t* h = nmmmq::create_handle(Allocator);
+            CHECK_EQUAL(0, nmmmq::attach_consumer(h, s_index_path, s_data_path, s_control_path));
+            CHECK_EQUAL(0, nmmmq::release_consumer(h, 0));
+
+            nmmmq::consumer_info_t info;
+            CHECK_EQUAL(0, nmmmq::inspect_consumer(h, 0, info));
+            CHECK_FALSE(info.active);
+
+            // the released slot is the first free one and starts at the requested sequence number
+            i32 slot = -1;
+            CHECK_EQUAL(0, nmmmq::register_consumer(h, "replacement", 0, slot));
+            CHECK_EQUAL(0, slot);
+
+            const u8* msg_data = nullptr;
+            u32       msg_len  = 0;
+            CHECK_TRUE(nmmmq::consumer_drain(h, slot, msg_data, msg_len));
+            CHECK_EQUAL('a', (char)msg_data[0]);
+
+            CHECK_TRUE(nmmmq::release_consumer(h, 4) < 0);
+
+            nmmmq::destroy_handle(h);
+            teardown_channel();
+        }
+
+        UNITTEST_TEST(partial_message)
+        {
+            CHECK_TRUE(setup_channel("corrupt/partial_message"));
+
+            nmmmq::handle_t* h = nmmmq::create_handle(Allocator);
+            CHECK_EQUAL(0, nmmmq::attach_consumer(h, s_index_path, s_data_path, s_control_path));
+
+            i32 slot = -1;
+            CHECK_EQUAL(0, nmmmq::register_consumer(h, "recovery", 0, slot));
+            CHECK_EQUAL(1, slot);
+
+            const u8* msg_data = nullptr;
+            u32       msg_len  = 0;
+            CHECK_TRUE(nmmmq::consumer_drain(h, slot, msg_data, msg_len));
+            CHECK_EQUAL('a', (char)msg_data[0]);
+            CHECK_TRUE(nmmmq::consumer_drain(h, slot, msg_data, msg_len));
+            CHECK_EQUAL('b', (char)msg_data[99]);
+
+            // the payload of the 3rd message is cut off, it is not delivered and the cursor stays
+            CHECK_FALSE(nmmmq::consumer_drain(h, slot, msg_data, msg_len));
+            CHECK_NULL(msg_data);
+
+            nmmmq::consumer_info_t info;
+            CHECK_EQUAL(0, nmmmq::inspect_consumer(h, slot, info));
+            CHECK_EQUAL((u64)2, info.last_seq);
+
+            nmmmq::destroy_handle(h);
+            teardown_channel();
+        }
+    }
+
+    UNITTEST_FIXTURE(layout)
+    {
+        UNITTEST_FIXTURE_SETUP() {}
+        UNITTEST_FIXTURE_TEARDOWN() {}
+
+        UNITTEST_ALLOCATOR;
+
+        // A channel written by an earlier build of this ABI version has to stay readable. When
+        // CMMIO_ABI_VERSION is bumped the v1 fixtures stay, these tests then have to show that such a
+        // channel is either migrated or refused.
+        UNITTEST_TEST(v1_inspector)
+        {
+            nmmmq::handle_t* h = nmmmq::create_handle(Allocator);
+            CHECK_EQUAL(0, nmmmq::attach_inspector(h, "data/layout/v1_index.bin", "data/layout/v1_data.bin", "data/layout/v1_control.bin"));
+
+            nmmmq::info_t info;
+            CHECK_EQUAL(0, nmmmq::inspect(h, info));
+            CHECK_EQUAL((u64)3, info.next_seq);
+            CHECK_EQUAL((u16)4, info.max_consumers);
+
+            nmmmq::consumer_info_t slot;
+            CHECK_EQUAL(0, nmmmq::inspect_consumer(h, 0, slot));
+            CHECK_TRUE(slot.active);
+            CHECK_EQUAL(0, strcmp("crashed", slot.name));
+            CHECK_EQUAL((u64)2, slot.last_seq);
+
+            nmmmq::destroy_handle(h);
+        }
+
+        UNITTEST_TEST(v1_consumer)
+        {
+            CHECK_TRUE(setup_channel("layout/v1"));
+
+            nmmmq::handle_t* h = nmmmq::create_handle(Allocator);
+            CHECK_EQUAL(0, nmmmq::attach_consumer(h, s_index_path, s_data_path, s_control_path));
+
+            i32 slot = -1;
+            CHECK_EQUAL(0, nmmmq::register_consumer(h, "upgraded", 0, slot));
+
+            const u8* msg_data = nullptr;
+            u32       msg_len  = 0;
+            for (u32 i = 0; i < 3; ++i)
+            {
+                CHECK_TRUE(nmmmq::consumer_drain(h, slot, msg_data, msg_len));
+                CHECK_EQUAL((u32)100, msg_len);
+                CHECK_EQUAL((char)('a' + i), (char)msg_data[99]);
+            }
+            CHECK_FALSE(nmmmq::consumer_drain(h, slot, msg_data, msg_len));
+
+            nmmmq::destroy_handle(h);
+            teardown_channel();
+        }
+
+        // a reader must not interpret the files of a newer layout, it refuses them before mapping
+        // anything behind the headers
+        UNITTEST_TEST(newer_inspector)
+        {
+            nmmmq::handle_t* h      = nmmmq::create_handle(Allocator);
+            const i32        result = nmmmq::attach_inspector(h, "data/layout/newer_index.bin", "data/layout/newer_data.bin", "data/layout/newer_control.bin");
+            CHECK_TRUE(result < 0);
+            CHECK_NOT_NULL(strstr(nmmmq::error_str(result), "index.mm sanity"));
+            nmmmq::destroy_handle(h);
+        }
+
+        UNITTEST_TEST(newer_consumer)
+        {
+            CHECK_TRUE(setup_channel("layout/newer"));
+
+            nmmmq::handle_t* h      = nmmmq::create_handle(Allocator);
+            const i32        result = nmmmq::attach_consumer(h, s_index_path, s_data_path, s_control_path);
+            CHECK_TRUE(result < 0);
+            CHECK_NOT_NULL(strstr(nmmmq::error_str(result), "index.mm sanity"));
+            nmmmq::destroy_handle(h);
+
+            teardown_channel();
+        }
+    }
+}
+UNITTEST_SUITE_END
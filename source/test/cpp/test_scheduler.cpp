@@ -0,0 +1,183 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_mmmq.h"
+#include "cmmio/c_scheduler.h"
+
+#include "cunittest/cunittest.h"
+
+#include <stdio.h>
+#include <unistd.h>
+#include <semaphore.h>
+
+using namespace ncore;
+
+// Two channels "test_sched_a_*" and "test_sched_b_*", each with a producer and a registered consumer.
+struct sched_channels_t
+{
+    nmmmq::handle_t* m_producer[2];
+    nmmmq::handle_t* m_consumer[2];
+    i32              m_slot[2];
+};
+
+static bool open_channels(alloc_t* allocator, sched_channels_t& ch)
+{
+    bool ok = true;
+    for (i32 i = 0; i < 2; ++i)
+    {
+        const char name = (char)('a' + i);
+        char       index_path[32], data_path[32], control_path[32], new_sem[32], reg_sem[32];
+        snprintf(index_path, sizeof(index_path), "test_sched_%c_index.mm", name);
+        snprintf(data_path, sizeof(data_path), "test_sched_%c_data.mm", name);
+        snprintf(control_path, sizeof(control_path), "test_sched_%c_control.mm", name);
+        snprintf(new_sem, sizeof(new_sem), "test_sched_%c_new_sem", name);
+        snprintf(reg_sem, sizeof(reg_sem), "test_sched_%c_reg_sem", name);
+
+        ch.m_producer[i] = nmmmq::create_handle(allocator);
+        ch.m_consumer[i] = nmmmq::create_handle(allocator);
+        ch.m_slot[i]     = -1;
+
+        nmmmq::config_t config(64 * 1024, 64 * 1024, 4);
+        ok = ok && nmmmq::init_producer(ch.m_producer[i], config, index_path, data_path, control_path, new_sem, reg_sem) == 0;
+        ok = ok && nmmmq::attach_consumer(ch.m_consumer[i], index_path, data_path, control_path) == 0;
+        ok = ok && nmmmq::register_consumer(ch.m_consumer[i], "scheduler", 0, ch.m_slot[i]) == 0;
+    }
+    return ok;
+}
+
+static void close_channels(sched_channels_t& ch)
+{
+    for (i32 i = 0; i < 2; ++i)
+    {
+        nmmmq::destroy_handle(ch.m_consumer[i]);
+        nmmmq::destroy_handle(ch.m_producer[i]);
+
+        const char name = (char)('a' + i);
+        char       path[32];
+        snprintf(path, sizeof(path), "test_sched_%c_index.mm", name);
+        unlink(path);
+        snprintf(path, sizeof(path), "test_sched_%c_data.mm", name);
+        unlink(path);
+        snprintf(path, sizeof(path), "test_sched_%c_control.mm", name);
+        unlink(path);
+        snprintf(path, sizeof(path), "test_sched_%c_new_sem", name);
+        sem_unlink(path);
+        snprintf(path, sizeof(path), "test_sched_%c_reg_sem", name);
+        sem_unlink(path);
+    }
+}
+
+static bool publish_n(nmmmq::handle_t* p, u32 count)
+{
+    bool ok = true;
+    for (u32 i = 0; i < count && ok; ++i)
+        ok = nmmmq::publish(p, &i, sizeof(i)) == 0;
+    return ok;
+}
+
+UNITTEST_SUITE_BEGIN(scheduler)
+{
+    UNITTEST_FIXTURE(weighted)
+    {
+        UNITTEST_FIXTURE_SETUP() {}
+        UNITTEST_FIXTURE_TEARDOWN() {}
+
+        UNITTEST_ALLOCATOR;
+
+        UNITTEST_TEST(add_channel)
+        {
+            sched_channels_t ch;
+            CHECK_TRUE(open_channels(Allocator, ch));
+
+            nscheduler::scheduler_t* s = nscheduler::create_scheduler(Allocator, 1);
+            CHECK_EQUAL(-1, nscheduler::add_channel(s, ch.m_consumer[0], ch.m_slot[0], 0));
+            CHECK_EQUAL(0, nscheduler::add_channel(s, ch.m_consumer[0], ch.m_slot[0], 1));
+            CHECK_EQUAL(-1, nscheduler::add_channel(s, ch.m_consumer[1], ch.m_slot[1], 1));
+
+            // nothing published
+            i32       channel  = -1;
+            u8 const* msg_data = nullptr;
+            u32       msg_len  = 0;
+            CHECK_FALSE(nscheduler::next(s, channel, msg_data, msg_len));
+
+            nscheduler::destroy_scheduler(s);
+            CHECK_NULL(s);
+            close_channels(ch);
+        }
+
+        UNITTEST_TEST(weights)
+        {
+            sched_channels_t ch;
+            CHECK_TRUE(open_channels(Allocator, ch));
+            CHECK_TRUE(publish_n(ch.m_producer[0], 8));
+            CHECK_TRUE(publish_n(ch.m_producer[1], 8));
+
+            nscheduler::scheduler_t* s = nscheduler::create_scheduler(Allocator, 2);
+            CHECK_EQUAL(0, nscheduler::add_channel(s, ch.m_consumer[0], ch.m_slot[0], 3));
+            CHECK_EQUAL(1, nscheduler::add_channel(s, ch.m_consumer[1], ch.m_slot[1], 1));
+
+            // 3 messages of a for every message of b, in the order they were published per channel
+            const i32 expected[] = {0, 0, 0, 1, 0, 0, 0, 1};
+            u32       next_a = 0, next_b = 0;
+            i32       channel  = -1;
+            u8 const* msg_data = nullptr;
+            u32       msg_len  = 0;
+            for (u32 i = 0; i < 8; ++i)
+            {
+                CHECK_TRUE(nscheduler::next(s, channel, msg_data, msg_len));
+                CHECK_EQUAL(expected[i], channel);
+                CHECK_EQUAL((u32)sizeof(u32), msg_len);
+                CHECK_EQUAL(channel == 0 ? next_a++ : next_b++, *(const u32*)msg_data);
+            }
+
+            // a runs out after 2 more, from then on b gets every turn
+            u32 count_a = 0, count_b = 0;
+            while (nscheduler::next(s, channel, msg_data, msg_len))
+            {
+                if (channel == 0)
+                    count_a++;
+                else
+                    count_b++;
+            }
+            CHECK_EQUAL((u32)2, count_a);
+            CHECK_EQUAL((u32)6, count_b);
+            CHECK_NULL(msg_data);
+
+            nscheduler::destroy_scheduler(s);
+            close_channels(ch);
+        }
+
+        UNITTEST_TEST(no_starvation)
+        {
+            sched_channels_t ch;
+            CHECK_TRUE(open_channels(Allocator, ch));
+            CHECK_TRUE(publish_n(ch.m_producer[0], 1000));
+
+            nscheduler::scheduler_t* s = nscheduler::create_scheduler(Allocator, 2);
+            CHECK_EQUAL(0, nscheduler::add_channel(s, ch.m_consumer[0], ch.m_slot[0], 4));
+            CHECK_EQUAL(1, nscheduler::add_channel(s, ch.m_consumer[1], ch.m_slot[1], 1));
+
+            i32       channel  = -1;
+            u8 const* msg_data = nullptr;
+            u32       msg_len  = 0;
+            for (u32 i = 0; i < 10; ++i)
+            {
+                CHECK_TRUE(nscheduler::next(s, channel, msg_data, msg_len));
+                CHECK_EQUAL(0, channel);
+            }
+
+            // a message on the quiet channel is delivered within one round of the chatty one
+            CHECK_TRUE(publish_n(ch.m_producer[1], 1));
+            bool delivered = false;
+            for (u32 i = 0; i < 5 && !delivered; ++i)
+            {
+                CHECK_TRUE(nscheduler::next(s, channel, msg_data, msg_len));
+                delivered = channel == 1;
+            }
+            CHECK_TRUE(delivered);
+
+            nscheduler::destroy_scheduler(s);
+            close_channels(ch);
+        }
+    }
+}
+UNITTEST_SUITE_END
@@ -0,0 +1,248 @@
+#include "ccore/c_target.h"
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_mmmq.h"
+#include "cmmio/c_version.h"
+
+#include <stdio.h>
+#include <string.h>
+#include <unistd.h>
+#include <semaphore.h>
+#include <cstdlib>
+
+class malloc_based_alloc_t : public ncore::alloc_t
+{
+public:
+    void* v_allocate(ncore::u32 size, ncore::u32 alignment) { return malloc(size); }
+    void  v_deallocate(void* ptr) { free(ptr); }
+};
+
+namespace ncore
+{
+    static malloc_based_alloc_t s_malloc_based_alloc;
+    static alloc_t*             s_allocator = &s_malloc_based_alloc;
+
+    static const char* s_new_sem_name = "mmq_fixture_new_entries_sem";
+    static const char* s_reg_sem_name = "mmq_fixture_registry_lock_sem";
+    // Writes @size bytes of a fixed pattern, the same inputs always produce the same file.
+    static bool write_pattern(const char* dir, const char* filename, u32 size, u32 seed)
+    {
+        char path[512];
+        snprintf(path, sizeof(path), "%s/%s", dir, filename);
+
+        FILE* f = fopen(path, "wb");
+        if (f == nullptr)
+        {
+            printf("testdata-gen: cannot create '%s'\n", path);
+            return false;
+        }
+
+        u32 state = seed;
+        for (u32 i = 0; i < size; ++i)
+        {
+            state = state * 1664525u + 1013904223u;
+            fputc((int)(state >> 24), f);
+        }
+        fclose(f);
+
+        printf("testdata-gen: wrote '%s' (%u bytes)\n", path, size);
+        return true;
+    }
+
+    // Copies the first @size bytes of @src to @dir/@filename, all of it when @size is 0.
+    static bool write_copy(const char* src, const char* dir, const char* filename, u32 size)
+    {
+        char path[512];
+        snprintf(path, sizeof(path), "%s/%s", dir, filename);
+
+        FILE* in = fopen(src, "rb");
+        if (in == nullptr)
+        {
+            printf("testdata-gen: cannot open '%s'\n", src);
+            return false;
+        }
+        FILE* out = fopen(path, "wb");
+        if (out == nullptr)
+        {
+            printf("testdata-gen: cannot create '%s'\n", path);
+            fclose(in);
+            return false;
+        }
+
+        u32 written = 0;
+        int c;
+        while ((size == 0 || written < size) && (c = fgetc(in)) != EOF)
+        {
+            fputc(c, out);
+            written++;
+        }
+        fclose(out);
+        fclose(in);
+
+        printf("testdata-gen: wrote '%s' (%u bytes)\n", path, written);
+        return true;
+    }
+
+    // Overwrites @size bytes at @offset of the file at @path with @value.
+    static bool write_patch(const char* path, u32 offset, const void* value, u32 size)
+    {
+        FILE* f = fopen(path, "r+b");
+        if (f == nullptr)
+        {
+            printf("testdata-gen: cannot open '%s'\n", path);
+            return false;
+        }
+        const bool ok = fseek(f, (long)offset, SEEK_SET) == 0 && fwrite(value, 1, size, f) == size;
+        fclose(f);
+
+        if (!ok)
+            printf("testdata-gen: cannot patch '%s'\n", path);
+        return ok;
+    }
+
+    // Runs a small channel to completion, 3 messages of 100 bytes, and a consumer called "crashed"
+    // that read 2 of them and then never came back.
+    static bool write_channel(const char* index_path, const char* data_path, const char* control_path)
+    {
+        unlink(index_path);
+        unlink(data_path);
+        unlink(control_path);
+
+        nmmmq::handle_t* p = nmmmq::create_handle(s_allocator);
+        nmmmq::handle_t* c = nmmmq::create_handle(s_allocator);
+
+        nmmmq::config_t config(1024, 1024, 4);
+        bool            ok = nmmmq::init_producer(p, config, index_path, data_path, control_path, s_new_sem_name, s_reg_sem_name) >= 0;
+        ok                 = ok && nmmmq::attach_consumer(c, index_path, data_path, control_path) >= 0;
+
+        i32 slot = -1;
+        ok       = ok && nmmmq::register_consumer(c, "crashed", 0, slot) >= 0;
+
+        u8 msg[100];
+        for (u32 i = 0; i < 3 && ok; ++i)
+        {
+            memset(msg, 'a' + i, sizeof(msg));
+            ok = nmmmq::publish(p, msg, sizeof(msg)) >= 0;
+        }
+
+        const u8* msg_data = nullptr;
+        u32       msg_len  = 0;
+        for (u32 i = 0; i < 2 && ok; ++i)
+            ok = nmmmq::consumer_drain(c, slot, msg_data, msg_len);
+
+        nmmmq::destroy_handle(c);
+        nmmmq::destroy_handle(p);
+        sem_unlink(s_new_sem_name);
+        sem_unlink(s_reg_sem_name);
+
+        // the heartbeat of slot 0 (behind the 128 byte header of control.mm) is a timestamp, clear
+        // it so that the fixtures do not change with every run
+        const u64 no_heartbeat = 0;
+        ok                     = ok && write_patch(control_path, 128, &no_heartbeat, sizeof(no_heartbeat));
+
+        if (!ok)
+            printf("testdata-gen: failed to run the fixture channel\n");
+        return ok;
+    }
+
+    // Writes the channels left behind by a producer that died, for the recovery tests. Every
+    // scenario is a set of 3 files, <name>_index.bin, <name>_data.bin and <name>_control.bin:
+    // - torn_header: index.mm ends in the middle of its header
+    // - stale_consumer: consumer "crashed" is still registered with 1 message left to read
+    // - partial_message: data.mm ends in the middle of the payload of the 3rd message
+    static bool write_corrupt_channels(const char* dir)
+    {
+        char corrupt[512];
+        snprintf(corrupt, sizeof(corrupt), "%s/corrupt", dir);
+
+        char index_path[512], data_path[512], control_path[512];
+        snprintf(index_path, sizeof(index_path), "%s/channel_index.tmp", corrupt);
+        snprintf(data_path, sizeof(data_path), "%s/channel_data.tmp", corrupt);
+        snprintf(control_path, sizeof(control_path), "%s/channel_control.tmp", corrupt);
+
+        bool ok = write_channel(index_path, data_path, control_path);
+        if (ok)
+        {
+            // data.mm header (32 bytes) + 2 messages (104 bytes each) + half of the 3rd message
+            const u32 partial_data_size = 32 + 2 * 104 + 50;
+
+            ok = write_copy(index_path, corrupt, "torn_header_index.bin", 16) && ok;
+            ok = write_copy(data_path, corrupt, "torn_header_data.bin", 0) && ok;
+            ok = write_copy(control_path, corrupt, "torn_header_control.bin", 0) && ok;
+            ok = write_copy(index_path, corrupt, "stale_consumer_index.bin", 0) && ok;
+            ok = write_copy(data_path, corrupt, "stale_consumer_data.bin", 0) && ok;
+            ok = write_copy(control_path, corrupt, "stale_consumer_control.bin", 0) && ok;
+            ok = write_copy(index_path, corrupt, "partial_message_index.bin", 0) && ok;
+            ok = write_copy(data_path, corrupt, "partial_message_data.bin", partial_data_size) && ok;
+            ok = write_copy(control_path, corrupt, "partial_message_control.bin", 0) && ok;
+        }
+
+        unlink(index_path);
+        unlink(data_path);
+        unlink(control_path);
+        return ok;
+    }
+
+    // Writes the channels for the layout tests, in the same 3 file sets as the corrupt channels:
+    // - v<CMMIO_ABI_VERSION>: the channel as written by this version of the library, it stays in the
+    //   repository when the ABI version is bumped so that the tests show what happens to channels
+    //   that outlive an upgrade
+    // - newer: the same channel with the version in all 3 headers set to CMMIO_ABI_VERSION + 1,
+    //   a channel created by a library that is newer than the reader
+    static bool write_layout_channels(const char* dir)
+    {
+        char layout[512];
+        snprintf(layout, sizeof(layout), "%s/layout", dir);
+
+        char index_path[512], data_path[512], control_path[512];
+        snprintf(index_path, sizeof(index_path), "%s/channel_index.tmp", layout);
+        snprintf(data_path, sizeof(data_path), "%s/channel_data.tmp", layout);
+        snprintf(control_path, sizeof(control_path), "%s/channel_control.tmp", layout);
+
+        bool ok = write_channel(index_path, data_path, control_path);
+        if (ok)
+        {
+            char current[3][32];
+            snprintf(current[0], sizeof(current[0]), "v%u_index.bin", (u32)CMMIO_ABI_VERSION);
+            snprintf(current[1], sizeof(current[1]), "v%u_data.bin", (u32)CMMIO_ABI_VERSION);
+            snprintf(current[2], sizeof(current[2]), "v%u_control.bin", (u32)CMMIO_ABI_VERSION);
+
+            // the version follows the 8 byte magic, it is a u32 in index.mm and data.mm and a u16 in control.mm
+            const u32 newer32 = CMMIO_ABI_VERSION + 1;
+            const u16 newer16 = CMMIO_ABI_VERSION + 1;
+
+            ok = write_copy(index_path, layout, current[0], 0) && ok;
+            ok = write_copy(data_path, layout, current[1], 0) && ok;
+            ok = write_copy(control_path, layout, current[2], 0) && ok;
+            ok = write_copy(index_path, layout, "newer_index.bin", 0) && ok;
+            ok = write_copy(data_path, layout, "newer_data.bin", 0) && ok;
+            ok = write_copy(control_path, layout, "newer_control.bin", 0) && ok;
+            char newer[3][512];
+            snprintf(newer[0], sizeof(newer[0]), "%s/newer_index.bin", layout);
+            snprintf(newer[1], sizeof(newer[1]), "%s/newer_data.bin", layout);
+            snprintf(newer[2], sizeof(newer[2]), "%s/newer_control.bin", layout);
+            ok = ok && write_patch(newer[0], 8, &newer32, sizeof(newer32));
+            ok = ok && write_patch(newer[1], 8, &newer32, sizeof(newer32));
+            ok = ok && write_patch(newer[2], 8, &newer16, sizeof(newer16));
+        }
+
+        unlink(index_path);
+        unlink(data_path);
+        unlink(control_path);
+        return ok;
+    }
+
+    // Generates the binary fixtures used by the unittest, by default into source/test/data
+    // when run from the root of the repository (the corrupt/ and layout/ sub directories have to exist).
+    int AppMain(int argc, const char** argv)
+    {
+        const char* dir = (argc >= 2) ? argv[1] : "source/test/data";
+
+        bool ok = true;
+        ok      = write_pattern(dir, "test.bin", 55328, 0x0C33105u) && ok;
+        ok      = write_corrupt_channels(dir) && ok;
+        ok      = write_layout_channels(dir) && ok;
+        return ok ? 0 : -1;
+    }
+
+}  // namespace ncore
@@ -0,0 +1,192 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_capture.h"
+#include "cmmio/c_mmmq.h"
+
+#include <unistd.h>
+#include <string.h>
+#include <stdio.h>
+#include <cstdlib>
+#include <time.h>
+
+class malloc_based_alloc_t : public ncore::alloc_t
+{
+public:
+    void* v_allocate(ncore::u32 size, ncore::u32 alignment) { return malloc(size); }
+    void  v_deallocate(void* ptr) { free(ptr); }
+};
+
+namespace ncore
+{
+    static malloc_based_alloc_t s_malloc_based_alloc;
+    static alloc_t*             s_allocator = &s_malloc_based_alloc;
+
+    // The output is the JSON object format of the Trace Event Format, it can be loaded in
+    // chrome://tracing and in Perfetto (ui.perfetto.dev). Timestamps are in microseconds.
+
+    static u64 now_ns()
+    {
+        struct timespec ts;
+        clock_gettime(CLOCK_MONOTONIC, &ts);
+        return (u64)ts.tv_sec * 1000000000ull + (u64)ts.tv_nsec;
+    }
+
+    static void trace_begin(FILE* out, const char* process_name)
+    {
+        fprintf(out, "{\"displayTimeUnit\": \"ms\", \"traceEvents\": [\n");
+        fprintf(out, "{\"name\": \"process_name\", \"ph\": \"M\", \"pid\": 1, \"tid\": 1, \"args\": {\"name\": \"%s\"}}", process_name);
+    }
+
+    static void trace_end(FILE* out) { fprintf(out, "\n]}\n"); }
+
+    static void trace_counter(FILE* out, const char* name, u64 ts_ns, const char* series, u64 value)
+    {
+        fprintf(out, ",\n{\"name\": \"%s\", \"ph\": \"C\", \"ts\": %.3f, \"pid\": 1, \"tid\": 1, \"args\": {\"%s\": %llu}}", name, (double)ts_ns / 1000.0, series, (unsigned long long)value);
+    }
+
+    // Every frame of a capture becomes an instant event, a gap of more than @stall_ms between two
+    // frames becomes a "stall" slice so that it stands out on the timeline.
+    static int from_capture(const char* capture_path, const char* trace_path, u32 stall_ms)
+    {
+        ncapture::reader_t* in     = nullptr;
+        i32                 result = ncapture::open_reader(s_allocator, capture_path, in);
+        if (result != 0)
+        {
+            printf("cmmio-trace: cannot open '%s' (err = %s)\n", capture_path, ncapture::error_str(result));
+            return -1;
+        }
+
+        FILE* out = fopen(trace_path, "w");
+        if (out == nullptr)
+        {
+            printf("cmmio-trace: cannot create '%s'\n", trace_path);
+            ncapture::close_reader(in);
+            return -1;
+        }
+
+        trace_begin(out, capture_path);
+
+        const u64         stall_ns = (u64)stall_ms * 1000000ull;
+        u64               prev_ns  = 0;
+        u64               bytes    = 0;
+        u32               count    = 0;
+        u32               stalls   = 0;
+        ncapture::frame_t frame;
+        const u8*         payload  = nullptr;
+        while ((result = ncapture::read_frame(in, frame, payload)) == ncapture::CAPTURE_OK)
+        {
+            if (count > 0 && (frame.m_time_ns - prev_ns) > stall_ns)
+            {
+                fprintf(out, ",\n{\"name\": \"stall\", \"ph\": \"X\", \"ts\": %.3f, \"dur\": %.3f, \"pid\": 1, \"tid\": 1}", (double)prev_ns / 1000.0, (double)(frame.m_time_ns - prev_ns) / 1000.0);
+                stalls++;
+            }
+
+            fprintf(out, ",\n{\"name\": \"message\", \"ph\": \"i\", \"s\": \"t\", \"ts\": %.3f, \"pid\": 1, \"tid\": 1, \"args\": {\"seq\": %u, \"len\": %u}}", (double)frame.m_time_ns / 1000.0, count, frame.m_len);
+            bytes += frame.m_len;
+            trace_counter(out, "payload", frame.m_time_ns, "bytes", bytes);
+
+            prev_ns = frame.m_time_ns;
+            count++;
+        }
+
+        trace_end(out);
+        fclose(out);
+        ncapture::close_reader(in);
+        if (result != ncapture::CAPTURE_END)
+        {
+            printf("cmmio-trace: frame %u of '%s' cannot be read (err = %s)\n", count, capture_path, ncapture::error_str(result));
+            return -1;
+        }
+        printf("cmmio-trace: wrote %u messages and %u stalls to '%s'\n", count, stalls, trace_path);
+        return 0;
+    }
+
+    // Samples a live channel, the published sequence number, data usage and the lag of every
+    // active consumer become counters, a lag that keeps growing is a consumer that falls behind.
+    static int from_channel(const char* trace_path, u32 num_seconds, u32 interval_ms, const char* index_path, const char* data_path, const char* control_path)
+    {
+        nmmmq::handle_t* h      = nmmmq::create_handle(s_allocator);
+        i32              result = nmmmq::attach_inspector(h, index_path, data_path, control_path);
+        if (result != 0)
+        {
+            printf("cmmio-trace: %s\n", nmmmq::error_str(result));
+            nmmmq::destroy_handle(h);
+            return -1;
+        }
+
+        FILE* out = fopen(trace_path, "w");
+        if (out == nullptr)
+        {
+            printf("cmmio-trace: cannot create '%s'\n", trace_path);
+            nmmmq::destroy_handle(h);
+            return -1;
+        }
+
+        trace_begin(out, index_path);
+
+        const u64 begin   = now_ns();
+        const u64 end     = begin + (u64)num_seconds * 1000000000ull;
+        u32       samples = 0;
+        for (u64 t = begin; t < end && result == 0; t = now_ns())
+        {
+            nmmmq::info_t info;
+            result = nmmmq::inspect(h, info);
+            if (result != 0)
+                break;
+
+            const u64 ts = t - begin;
+            trace_counter(out, "published", ts, "next_seq", info.next_seq);
+            trace_counter(out, "data", ts, "bytes", info.data_write_pos);
+            for (i32 i = 0; i < (i32)info.max_consumers; ++i)
+            {
+                nmmmq::consumer_info_t ci;
+                if (nmmmq::inspect_consumer(h, i, ci) != 0 || !ci.active)
+                    continue;
+
+                char name[64];
+                snprintf(name, sizeof(name), "lag %s", ci.name);
+                trace_counter(out, name, ts, "messages", (info.next_seq > ci.last_seq) ? (info.next_seq - ci.last_seq) : 0);
+            }
+
+            samples++;
+            usleep(interval_ms * 1000);
+        }
+
+        trace_end(out);
+        fclose(out);
+        nmmmq::destroy_handle(h);
+
+        if (result != 0)
+        {
+            printf("cmmio-trace: %s\n", nmmmq::error_str(result));
+            return -1;
+        }
+        printf("cmmio-trace: wrote %u samples to '%s'\n", samples, trace_path);
+        return 0;
+    }
+
+    int AppMain(int argc, const char** argv)
+    {
+        if (argc >= 4 && strcmp(argv[1], "capture") == 0)
+        {
+            const u32 stall_ms = (argc >= 5) ? (u32)atoi(argv[4]) : 100;
+            return from_capture(argv[2], argv[3], stall_ms);
+        }
+        if (argc >= 3 && strcmp(argv[1], "live") == 0)
+        {
+            const u32   num_seconds  = (argc >= 4) ? (u32)atoi(argv[3]) : 10;
+            const u32   interval_ms  = (argc >= 5) ? (u32)atoi(argv[4]) : 10;
+            const char* index_path   = (argc >= 6) ? argv[5] : "index.mm";
+            const char* data_path    = (argc >= 7) ? argv[6] : "data.mm";
+            const char* control_path = (argc >= 8) ? argv[7] : "control.mm";
+            if (interval_ms == 0)
+                return -1;
+            return from_channel(argv[2], num_seconds, interval_ms, index_path, data_path, control_path);
+        }
+
+        printf("Usage: %s capture <capture.bin> <trace.json> [stall_ms]\n", argv[0]);
+        printf("       %s live <trace.json> [seconds] [interval_ms] [index_path] [data_path] [control_path]\n", argv[0]);
+        return -1;
+    }
+
+}  // namespace ncore
@@ -0,0 +1,216 @@
+#include "ccore/c_allocator.h"
+
+#include "cmmio/c_mmmq.h"
+
+#include <unistd.h>
+#include <string.h>
+#include <stdio.h>
+#include <cstdlib>
+#include <math.h>
+#include <time.h>
+
+class malloc_based_alloc_t : public ncore::alloc_t
+{
+public:
+    void* v_allocate(ncore::u32 size, ncore::u32 alignment) { return malloc(size); }
+    void  v_deallocate(void* ptr) { free(ptr); }
+};
+
+namespace ncore
+{
+    static malloc_based_alloc_t s_malloc_based_alloc;
+
+    static u64 now_ns()
+    {
+        struct timespec ts;
+        clock_gettime(CLOCK_MONOTONIC, &ts);
+        return (u64)ts.tv_sec * 1000000000ull + (u64)ts.tv_nsec;
+    }
+
+    // xorshift64, a fixed seed gives the same traffic on every run
+    static u64 next_random(u64& state)
+    {
+        state ^= state << 13;
+        state ^= state >> 7;
+        state ^= state << 17;
+        return state;
+    }
+
+    // uniform in (0, 1]
+    static double next_uniform(u64& state) { return (double)((next_random(state) >> 11) + 1) * (1.0 / 9007199254740992.0); }
+
+    // exponentially distributed gap in nanoseconds for events arriving at @rate per second
+    static u64 next_gap_ns(u64& state, double rate) { return (u64)(-log(next_uniform(state)) * 1e9 / rate); }
+
+    // The start of every message, the rest up to the message size is padding. seq comes first
+    // like in the messages of cmmio-synthetic-producer.
+    struct tick_t
+    {
+        u64 seq;
+        u64 publish_ns;  // CLOCK_MONOTONIC, lets a consumer measure the end-to-end latency
+        u32 instrument;
+        u32 side;   // 0 = bid, 1 = ask
+        i64 price;  // in 1/10000 of the quote currency
+        u32 quantity;
+        u32 burst;  // 0 for the poisson model, else the number of the burst (1 based)
+    };
+
+    enum model_t
+    {
+        MODEL_POISSON,  // messages arrive independently at --rate per second
+        MODEL_BURST,    // bursts arrive at --burst-rate per second, each with up to --burst messages back to back
+    };
+
+    static int usage(const char* app)
+    {
+        printf("Usage: %s [--model poisson|burst] [--rate <msgs/s>] [--burst <msgs>] [--burst-rate <bursts/s>]\n", app);
+        printf("       [--count <n>] [--instruments <n>] [--min-size <bytes>] [--max-size <bytes>] [--seed <n>] [prefix]\n");
+        printf("Publishes --count market-data messages (default 100000) to the channel <prefix>index.mm, <prefix>data.mm,\n");
+        printf("<prefix>control.mm. poisson (default) spaces the messages with exponential gaps at --rate (default 10000),\n");
+        printf("burst sends bursts of 1 to --burst messages (default 64) with exponential gaps at --burst-rate (default 100).\n");
+        return -1;
+    }
+
+    // Generates bursty, small message traffic shaped like a market-data feed, to drive cmmio-bench,
+    // cmmio-soak or any other consumer of a channel.
+    int AppMain(int argc, const char** argv)
+    {
+        model_t     model       = MODEL_POISSON;
+        double      rate        = 10000.0;
+        u32         burst       = 64;
+        double      burst_rate  = 100.0;
+        u64         count       = 100000;
+        u32         instruments = 500;
+        u32         min_size    = sizeof(tick_t);
+        u32         max_size    = 96;
+        u64         seed        = 0x9E3779B97F4A7C15ull;
+        const char* prefix      = "";
+        for (int i = 1; i < argc; ++i)
+        {
+            if (strcmp(argv[i], "--model") == 0 && i + 1 < argc)
+            {
+                ++i;
+                if (strcmp(argv[i], "poisson") == 0)
+                    model = MODEL_POISSON;
+                else if (strcmp(argv[i], "burst") == 0)
+                    model = MODEL_BURST;
+                else
+                    return usage(argv[0]);
+            }
+            else if (strcmp(argv[i], "--rate") == 0 && i + 1 < argc)
+                rate = atof(argv[++i]);
+            else if (strcmp(argv[i], "--burst") == 0 && i + 1 < argc)
+                burst = (u32)atoi(argv[++i]);
+            else if (strcmp(argv[i], "--burst-rate") == 0 && i + 1 < argc)
+                burst_rate = atof(argv[++i]);
+            else if (strcmp(argv[i], "--count") == 0 && i + 1 < argc)
+                count = (u64)strtoull(argv[++i], nullptr, 10);
+            else if (strcmp(argv[i], "--instruments") == 0 && i + 1 < argc)
+                instruments = (u32)atoi(argv[++i]);
+            else if (strcmp(argv[i], "--min-size") == 0 && i + 1 < argc)
+                min_size = (u32)atoi(argv[++i]);
+            else if (strcmp(argv[i], "--max-size") == 0 && i + 1 < argc)
+                max_size = (u32)atoi(argv[++i]);
+            else if (strcmp(argv[i], "--seed") == 0 && i + 1 < argc)
+                seed = (u64)strtoull(argv[++i], nullptr, 10);
+            else if (argv[i][0] != '-')
+                prefix = argv[i];
+            else
+                return usage(argv[0]);
+        }
+        if (rate <= 0.0 || burst == 0 || burst_rate <= 0.0 || instruments == 0 || min_size < sizeof(tick_t) || max_size < min_size || seed == 0)
+            return usage(argv[0]);
+
+        char index_path[256], data_path[256], control_path[256];
+        char new_sem_name[52], reg_sem_name[52];
+        snprintf(index_path, sizeof(index_path), "%sindex.mm", prefix);
+        snprintf(data_path, sizeof(data_path), "%sdata.mm", prefix);
+        snprintf(control_path, sizeof(control_path), "%scontrol.mm", prefix);
+        snprintf(new_sem_name, sizeof(new_sem_name), "%smmq_new_entries_sem", prefix);
+        snprintf(reg_sem_name, sizeof(reg_sem_name), "%smmq_registry_lock_sem", prefix);
+
+        nmmmq::handle_t* h = nmmmq::create_handle(&s_malloc_based_alloc);
+        nmmmq::config_t  config(1 * cMB, 10 * cMB, 16);
+        i32              result = nmmmq::init_producer(h, config, index_path, data_path, control_path, new_sem_name, reg_sem_name);
+        if (result != 0)
+        {
+            printf("cmmio-workload: init_producer failed (err = %s)\n", nmmmq::error_str(result));
+            nmmmq::destroy_handle(h);
+            return -1;
+        }
+
+        u8* msg = (u8*)malloc(max_size);
+        memset(msg, 0, max_size);
+
+        // every instrument walks its own price, starting around 100.0000
+        i64* prices = (i64*)malloc(sizeof(i64) * instruments);
+        for (u32 i = 0; i < instruments; ++i)
+            prices[i] = 1000000 + (i64)(next_random(seed) % 10000);
+
+        const u64 begin      = now_ns();
+        u64       due        = begin;
+        u64       bytes      = 0;
+        u64       seq        = 0;
+        u32       bursts     = 0;
+        u32       burst_left = 0;
+        for (; seq < count && result == 0; ++seq)
+        {
+            if (model == MODEL_POISSON)
+            {
+                due += next_gap_ns(seed, rate);
+            }
+            else if (burst_left == 0)
+            {
+                due += next_gap_ns(seed, burst_rate);
+                burst_left = 1 + (u32)(next_random(seed) % burst);
+                bursts++;
+            }
+
+            // skewed towards the first instruments, a few of them are much busier than the rest
+            const double u          = next_uniform(seed);
+            const u32    instrument = (u32)((double)instruments * u * u * u) % instruments;
+            prices[instrument] += (i64)(next_random(seed) % 21) - 10;
+
+            tick_t* tick     = (tick_t*)msg;
+            tick->seq        = seq;
+            tick->instrument = instrument;
+            tick->side       = (u32)(next_random(seed) & 1);
+            tick->price      = prices[instrument];
+            tick->quantity   = 1 + (u32)(next_random(seed) % 1000);
+            tick->burst      = (model == MODEL_BURST) ? bursts : 0;
+            const u32 len    = min_size + (u32)(next_random(seed) % (u64)(max_size - min_size + 1));
+
+            // sleep towards the due time and spin the last stretch, usleep is too coarse for gaps of
+            // a few microseconds. Inside a burst due does not move, those messages go out back to back.
+            const u64 now = now_ns();
+            if (due > now + 100000)
+                usleep((useconds_t)((due - now - 50000) / 1000));
+            while (now_ns() < due) {}
+
+            tick->publish_ns = now_ns();
+            result           = nmmmq::publish(h, msg, len);
+            if (result == 0)
+                bytes += len;
+            if (burst_left > 0)
+                burst_left--;
+        }
+        const u64 elapsed = now_ns() - begin;
+
+        free(prices);
+        free(msg);
+        nmmmq::destroy_handle(h);
+
+        if (result != 0)
+        {
+            printf("cmmio-workload: publish failed at message %llu (err = %s)\n", (unsigned long long)(seq - 1), nmmmq::error_str(result));
+            return -1;
+        }
+        const double seconds = (double)elapsed / 1e9;
+        printf("cmmio-workload: published %llu messages", (unsigned long long)seq);
+        if (model == MODEL_BURST)
+            printf(" in %u bursts", bursts);
+        printf(", %llu bytes in %.2f s (%.0f msgs/s)\n", (unsigned long long)bytes, seconds, seconds > 0 ? (double)seq / seconds : 0.0);
+        return 0;
+    }
+
+}  // namespace ncore